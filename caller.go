@@ -2,6 +2,7 @@ package golog
 
 import (
 	"runtime"
+	"strings"
 	"sync"
 	_ "unsafe"
 )
@@ -38,12 +39,45 @@ func Caller(skip int) (file string, line int) {
 		return
 	}
 
-	pc := rpc[0]
+	frame := callerFrame(rpc[0])
+	return frame.File, frame.Line
+}
+
+// CallerPC returns the program counter of the caller, skipping the given number of stack
+// frames like Caller. It's 0 if unknown.
+// Unlike the file and line, the pc identifies the calling function even if several
+// functions (e.g. closures) are on the same line. It can be passed to Logger.LogPC().
+func CallerPC(skip int) uintptr {
+	rpc := [1]uintptr{}
+	n := callers(skip+1, rpc[:])
+	if n < 1 {
+		return 0
+	}
+	return rpc[0]
+}
+
+// callerFrame returns the frame of the pc returned by callers, cached by frameCache.
+func callerFrame(pc uintptr) runtime.Frame {
 	if f, ok := frameCache.Load(pc); ok {
-		frame := f.(runtime.Frame)
-		return frame.File, frame.Line
+		return f.(runtime.Frame)
 	}
 	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
 	frameCache.Store(pc, frame)
-	return frame.File, frame.Line
+	return frame
+}
+
+// splitFunctionName splits a fully qualified function name into its package path and
+// short function name, e.g. "github.com/keakon/golog" and "(*Logger).Log".
+func splitFunctionName(name string) (pkgPath, function string) {
+	lastSlash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[lastSlash+1:], '.')
+	if dot < 0 {
+		return "", name
+	}
+	dot += lastSlash + 1
+	pkgPath = name[:dot]
+	if strings.Contains(pkgPath, "%2e") { // the dots in the last element of the path are escaped
+		pkgPath = strings.ReplaceAll(pkgPath, "%2e", ".")
+	}
+	return pkgPath, name[dot+1:]
 }
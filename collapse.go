@@ -0,0 +1,100 @@
+package golog
+
+import (
+	"sync"
+	"time"
+)
+
+// A collapser drops the records identical to the last one, and reports how many
+// times it was repeated.
+type collapser struct {
+	lock     sync.Mutex
+	interval time.Duration
+	level    Level
+	file     string
+	line     int
+	message  string
+	repeated int
+	timer    *time.Timer // reports the repeated count if no other record comes, nil if not repeated
+	stopped  bool
+}
+
+// SetDuplicateCollapsing makes the handler collapse the consecutive identical records
+// (of the same level, source and message) into the first one and a record of "last
+// message repeated N times", like syslogd. The repeated count is reported when a
+// different record comes, or after the interval without other records.
+// Calling it again replaces the collapser and loses its repeated count.
+func (h *Handler) SetDuplicateCollapsing(interval time.Duration) {
+	if interval <= 0 {
+		interval = time.Second * 30
+	}
+	h.collapser = &collapser{interval: interval}
+}
+
+// collapse reports whether the record is different from the last one, reporting the
+// repeated count of the last one if so.
+func (c *collapser) collapse(h *Handler, r *Record) bool {
+	msg := r.Message()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped {
+		return true
+	}
+	if r.level == c.level && r.line == c.line && r.file == c.file && msg == c.message {
+		c.repeated++
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.interval, func() { c.flush(h) })
+		}
+		return false
+	}
+
+	if c.repeated > 0 {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		c.report(h)
+	}
+	c.level = r.level
+	c.file = r.file
+	c.line = r.line
+	c.message = msg
+	return true
+}
+
+// flush is called by the timer to report the repeated count.
+func (c *collapser) flush(h *Handler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.stopped && c.repeated > 0 {
+		c.report(h)
+	}
+}
+
+// report outputs the repeated count of the last record, then resets it.
+// The further identical records are counted again.
+// It should be called within a lock block.
+func (c *collapser) report(h *Handler) {
+	h.outputSummary(c.level, c.file, c.line, "last message repeated %d times", c.repeated)
+	c.repeated = 0
+	c.timer = nil
+}
+
+// stop reports the repeated count of the last record, and stops collapsing.
+func (c *collapser) stop(h *Handler) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.stopped {
+		return
+	}
+	if c.repeated > 0 {
+		if c.timer != nil {
+			c.timer.Stop()
+		}
+		c.report(h)
+	}
+	c.stopped = true
+}
@@ -0,0 +1,74 @@
+package golog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDuplicateCollapsing(t *testing.T) {
+	l, h, w := newCaptureLogger("%l %m")
+	h.SetDuplicateCollapsing(time.Hour)
+
+	for i := 0; i < 4; i++ {
+		l.Errorf("retry %d", 1)
+	}
+	l.Warnf("retry %d", 1) // another level
+	for i := 0; i < 2; i++ {
+		l.Warnf("retry %d", 2)
+	}
+	l.Warn("retry 2") // the same message formatted differently
+	l.Info("done")
+	l.Info("done")
+
+	expected := "E retry 1\nE last message repeated 3 times\n" +
+		"W retry 1\n" +
+		"W retry 2\nW last message repeated 2 times\n" +
+		"I done\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+
+	l.Close() // reports the last repeated count
+	expected += "I last message repeated 1 times\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestDuplicateCollapsingSource(t *testing.T) {
+	l, h, w := newCaptureLogger("%m")
+	h.SetDuplicateCollapsing(time.Hour)
+	defer l.Close()
+
+	l.Log(InfoLevel, "/src/a.go", 1, "same")
+	l.Log(InfoLevel, "/src/a.go", 2, "same") // another line
+	l.Log(InfoLevel, "/src/b.go", 2, "same") // another file
+	if s := w.String(); s != "same\nsame\nsame\n" {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestDuplicateCollapsingInterval(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	h.SetDuplicateCollapsing(time.Millisecond * 20)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Warn("stuck")
+	}
+	time.Sleep(time.Millisecond * 100) // no other record comes
+	if s := w.String(); s != "W stuck\nW last message repeated 2 times\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	l.Warn("stuck") // counted again
+	l.Warn("moving")
+	if s := w.String(); s != "W last message repeated 1 times\nW moving\n" {
+		t.Errorf("output is %q", s)
+	}
+}
@@ -0,0 +1,103 @@
+package golog
+
+import (
+	"bytes"
+	"os"
+)
+
+// ColorMode specifies how a ConsoleWriter colors the records.
+type ColorMode uint8
+
+const (
+	// ColorNone disables the colors.
+	ColorNone ColorMode = iota
+	// ColorLevel colors the level name (long or short) of each record, where it's
+	// rendered by the formatter.
+	ColorLevel
+	// ColorLine colors each whole record.
+	ColorLine
+)
+
+// The ANSI escape codes of the colors.
+const (
+	colorReset   = "\x1b[0m"
+	colorGray    = "\x1b[90m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorRed     = "\x1b[31m"
+	colorBoldRed = "\x1b[1;31m"
+)
+
+// isTerminal reports whether the file is a terminal.
+// It is defined as a variable in order to mock it in the unit testing.
+var isTerminal = func(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// colorEnabled reports whether the colors can be written to the file, i.e. it's a
+// terminal and the NO_COLOR environment variable is not set (see https://no-color.org).
+func colorEnabled(f *os.File) bool {
+	return f != nil && os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// ConsoleColor sets the color mode of a ConsoleWriter.
+// The colors are disabled if its file is not a terminal or NO_COLOR is set.
+// The debug records are gray, info green, warning yellow, error red, and critical
+// (and above) bold red. A custom level uses the color of the nearest lower built-in level.
+func ConsoleColor(mode ColorMode) ConsoleWriterOption {
+	return func(w *ConsoleWriter) {
+		w.color = mode
+	}
+}
+
+// levelColor returns the color of the level.
+func levelColor(lv Level) string {
+	switch {
+	case lv < InfoLevel:
+		return colorGray
+	case lv < WarnLevel:
+		return colorGreen
+	case lv < ErrorLevel:
+		return colorYellow
+	case lv < CritLevel:
+		return colorRed
+	default:
+		return colorBoldRed
+	}
+}
+
+// WriteRecord writes the formatted record, colored by its level if its color mode is set.
+// In the ColorLevel mode, the level name rendered by the formatter (the first one if
+// rendered more than once) is colored, and nothing is colored if it's not rendered.
+func (w *ConsoleWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	if w.color == ColorNone || r == nil || len(p) == 0 {
+		return w.File.Write(p)
+	}
+
+	start, end := 0, len(p)
+	if w.color == ColorLevel {
+		start, end = r.levelStart, r.levelEnd
+		if end == 0 || end > len(p) {
+			return w.File.Write(p)
+		}
+	} else if p[end-1] == '\n' {
+		end-- // keeps the newline uncolored
+	}
+
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	buf.Write(p[:start])
+	buf.WriteString(levelColor(r.level))
+	buf.Write(p[start:end])
+	buf.WriteString(colorReset)
+	buf.Write(p[end:])
+	_, err = w.File.Write(buf.Bytes())
+	if buf.Cap() <= maxPooledBufSize {
+		bufPool.Put(buf)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,106 @@
+package golog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConsoleColor(t *testing.T) {
+	isTTY := isTerminal
+	isTerminal = func(f *os.File) bool { return true }
+	defer func() { isTerminal = isTTY }()
+	t.Setenv("NO_COLOR", "")
+
+	path := filepath.Join(t.TempDir(), "console.log")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	for _, c := range []struct {
+		mode     ColorMode
+		format   string
+		level    Level
+		msg      string
+		expected string
+	}{
+		{ColorLevel, "[%l] %m", InfoLevel, "INFO", "[\x1b[32mI\x1b[0m] INFO\n"},
+		{ColorLevel, "%m %L", WarnLevel, "WARNING", "WARNING \x1b[33mWARN\x1b[0m\n"},
+		{ColorLevel, "%m", ErrorLevel, "no level", "no level\n"},
+		{ColorLevel, "%m", InfoLevel, "I am here", "I am here\n"},
+		{ColorLevel, "%m %l", InfoLevel, "I am", "I am \x1b[32mI\x1b[0m\n"},
+		{ColorLevel, "%l %L %m", InfoLevel, "twice", "\x1b[32mI\x1b[0m INFO twice\n"},
+		{ColorLevel, "%5l|%m", InfoLevel, "padded", "    \x1b[32mI\x1b[0m|padded\n"},
+		{ColorLevel, "%-6.3L|%m", WarnLevel, "truncated", "\x1b[33mWAR\x1b[0m   |truncated\n"},
+		{ColorLevel, "%.0L%m", WarnLevel, "hidden", "hidden\n"},
+		{ColorLevel, "%l %m", Level(26), "unregistered", "\x1b[32m?\x1b[0m unregistered\n"},
+		{ColorLine, "%l %m", DebugLevel, "debug", "\x1b[90mD debug\x1b[0m\n"},
+		{ColorLine, "%l %m", FatalLevel, "fatal", "\x1b[1;31mF fatal\x1b[0m\n"},
+		{ColorNone, "%l %m", ErrorLevel, "error", "E error\n"},
+	} {
+		if err = f.Truncate(0); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Seek(0, 0); err != nil {
+			t.Fatal(err)
+		}
+
+		w := NewConsoleWriter(f, ConsoleColor(c.mode))
+		h := NewHandler(DebugLevel, ParseFormat(c.format))
+		h.AddWriter(w)
+		l := NewLogger(DebugLevel)
+		l.AddHandler(h)
+		l.LogAt(c.level, c.msg)
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := string(content); s != c.expected {
+			t.Errorf("output is %q, expected %q", s, c.expected)
+		}
+	}
+}
+
+func TestConsoleColorDisabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "console.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if w := NewConsoleWriter(f, ConsoleColor(ColorLine)); w.color != ColorNone {
+		t.Error("enabled the color for a regular file")
+	}
+
+	isTTY := isTerminal
+	isTerminal = func(f *os.File) bool { return true }
+	defer func() { isTerminal = isTTY }()
+	t.Setenv("NO_COLOR", "1")
+	if w := NewConsoleWriter(f, ConsoleColor(ColorLine)); w.color != ColorNone {
+		t.Error("enabled the color while NO_COLOR is set")
+	}
+}
+
+func TestFormatLevelRange(t *testing.T) {
+	r := &Record{level: WarnLevel, file: "/src/main.go", line: 1, message: "WARN"}
+	for _, c := range []struct {
+		formatter *Formatter
+		start     int
+	}{
+		{DefaultFormatter, 1},
+		{TimedRotatingFormatter, 1},
+		{ParseFormat("%m %-6L"), 5},
+		{NewPrettyFormatter(), 9},
+	} {
+		buf := bytes.NewBufferString("prefix")
+		c.formatter.Format(r, buf)
+		p := buf.Bytes()[len("prefix"):]
+		if name := string(p[r.levelStart:r.levelEnd]); r.levelStart != c.start || (name != "W" && name != "WARN") {
+			t.Errorf("level range of %q is %d:%d", p, r.levelStart, r.levelEnd)
+		}
+	}
+}
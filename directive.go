@@ -0,0 +1,76 @@
+package golog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// customDirectives maps the names of the custom directives to the functions creating
+// their FormatParts.
+var customDirectives = map[string]func() FormatPart{}
+
+// RegisterDirective registers a custom directive, so ParseFormat() renders it by the
+// FormatPart created by newPart (once for each parsed format, so it can keep its own
+// state).
+// A single ASCII letter name can be used as "%x" or "%{x}", while a longer name can
+// be used as "%{name}". Both forms accept the modifiers, e.g. "%-10{request_id}".
+// The built-in directives cannot be replaced, but a custom one can be registered
+// again.
+// If the FormatPart has a NeedsCaller() method returning true, the formatter needs the
+// caller, so its record has the source file and line.
+// It should be called before parsing the formats (e.g. in an init function).
+func RegisterDirective(name string, newPart func() FormatPart) error {
+	if newPart == nil {
+		return errors.New("newPart cannot be nil")
+	}
+	if name == "" || strings.ContainsAny(name, "{}%") {
+		return fmt.Errorf("invalid directive name %q", name)
+	}
+	if len(name) == 1 {
+		c := name[0]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			return fmt.Errorf("directive %q is not an ASCII letter", name)
+		}
+		if (&Formatter{}).newBuiltinFormatPart(c) != nil {
+			return fmt.Errorf("directive %q is built-in", name)
+		}
+	}
+	customDirectives[name] = newPart
+	return nil
+}
+
+// newCustomFormatPart creates the FormatPart of a registered directive, or returns nil
+// if it's not registered.
+func (f *Formatter) newCustomFormatPart(name string) FormatPart {
+	newPart := customDirectives[name]
+	if newPart == nil {
+		return nil
+	}
+	part := newPart()
+	if cp, ok := part.(interface{ NeedsCaller() bool }); ok && cp.NeedsCaller() {
+		f.needsCaller = true
+	}
+	return part
+}
+
+// parseDirectiveName parses the name of a "{name}" directive at the beginning of the
+// format, and returns the name and the length of the "{name}".
+// The name is empty if the directive is not terminated.
+func parseDirectiveName(format []byte) (name string, n int) {
+	end := bytes.IndexByte(format, '}')
+	if end < 0 {
+		return "", len(format)
+	}
+	return string(format[1:end]), end + 1
+}
+
+// newNamedFormatPart creates the FormatPart of a "{name}" directive, or returns nil if
+// it's not a directive.
+func (f *Formatter) newNamedFormatPart(name string) FormatPart {
+	if len(name) == 1 {
+		return f.newFormatPart(name[0])
+	}
+	return f.newCustomFormatPart(name)
+}
@@ -0,0 +1,91 @@
+package golog
+
+import (
+	"bytes"
+	"path"
+	"testing"
+)
+
+type requestIDFormatPart struct{}
+
+func (p *requestIDFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	if args := r.Args(); len(args) > 0 {
+		if id, ok := args[0].(string); ok {
+			buf.WriteString(id)
+			return
+		}
+	}
+	buf.WriteByte('-')
+}
+
+type callerFormatPart struct{}
+
+func (p *callerFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	buf.WriteString(path.Base(r.Function()))
+}
+
+func (p *callerFormatPart) NeedsCaller() bool {
+	return true
+}
+
+func registerTestDirective(t *testing.T, name string, newPart func() FormatPart) {
+	t.Helper()
+	if err := RegisterDirective(name, newPart); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		delete(customDirectives, name)
+	})
+}
+
+func TestRegisterDirective(t *testing.T) {
+	newPart := func() FormatPart { return &requestIDFormatPart{} }
+	for _, name := range []string{"", "{x}", "a%b", "m", "%", "1"} {
+		if err := RegisterDirective(name, newPart); err == nil {
+			delete(customDirectives, name)
+			t.Errorf("registered directive %q", name)
+		}
+	}
+	if err := RegisterDirective("x", nil); err == nil {
+		t.Error("registered a nil directive")
+	}
+
+	registerTestDirective(t, "x", newPart)
+	registerTestDirective(t, "x", newPart) // can be registered again
+	registerTestDirective(t, "request_id", newPart)
+	registerTestDirective(t, "caller", func() FormatPart { return &callerFormatPart{} })
+
+	for format, expected := range map[string]string{
+		"%x %m":                "abc abc\n",
+		"%{x}|%{request_id}|":  "abc|abc|\n",
+		"%-5{request_id}|%.1x": "abc  |a\n",
+		"%{m}":                 "abc\n", // built-in
+		"%{unknown} %y %{x":    "%{unknown} %y %{x\n",
+		"%%{x}":                "%{x}\n",
+		"%{}":                  "%{}\n",
+	} {
+		f := ParseFormat(format)
+		if f.NeedsCaller() {
+			t.Errorf("format %q needs caller", format)
+		}
+		buf := &bytes.Buffer{}
+		f.Format(&Record{message: "%s", args: []interface{}{"abc"}}, buf)
+		if s := buf.String(); s != expected {
+			t.Errorf("format %q rendered %q, expected %q", format, s, expected)
+		}
+	}
+
+	f := ParseFormat("%{caller}")
+	if !f.NeedsCaller() {
+		t.Error("format doesn't need caller")
+	}
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, f)
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	l.Info("test")
+	if s := w.String(); s != "golog.TestRegisterDirective\n" {
+		t.Errorf("output is %q", s)
+	}
+}
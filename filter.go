@@ -0,0 +1,114 @@
+package golog
+
+import (
+	"regexp"
+	"strings"
+)
+
+// A Filter decides whether a record should be logged.
+// It can be added to a Logger (checked before all its handlers) or a Handler (checked
+// after its level). Its Filter() method should be thread-safe.
+//
+// If the filter has a NeedsCaller() method returning true (e.g. the one returned by
+// FileFilter), the source of the records will be computed even if no formatter renders
+// it; such a filter should be added before adding the handler to a logger.
+type Filter interface {
+	// Filter returns false to drop the record.
+	Filter(r *Record) bool
+}
+
+// FilterFunc is an adapter to use a function as a Filter.
+type FilterFunc func(r *Record) bool
+
+// Filter calls f(r).
+func (f FilterFunc) Filter(r *Record) bool {
+	return f(r)
+}
+
+// fileFilter drops the records lower than level from the matched source files.
+type fileFilter struct {
+	substr string
+	level  Level
+}
+
+// FileFilter returns a Filter which drops the records lower than level from the source
+// files whose paths contain substr.
+// e.g. FileFilter("/internal/retry/", WarnLevel) silences the debug and info records
+// of that directory, while keeping its warnings.
+// The path of a module dependency contains its version (e.g.
+// "github.com/chatty/dependency@v1.2.3/client.go"), so use PackageFilter to filter it.
+func FileFilter(substr string, level Level) Filter {
+	return &fileFilter{substr: substr, level: level}
+}
+
+// Filter implements Filter.
+func (f *fileFilter) Filter(r *Record) bool {
+	return r.level >= f.level || !strings.Contains(r.file, f.substr)
+}
+
+// NeedsCaller returns true, since the source file is needed to filter the records.
+func (f *fileFilter) NeedsCaller() bool {
+	return true
+}
+
+// packageFilter drops the records lower than level from the matched packages.
+type packageFilter struct {
+	pkgPath string
+	level   Level
+}
+
+// PackageFilter returns a Filter which drops the records lower than level from the
+// package whose import path is pkgPath, and from its subpackages.
+// e.g. PackageFilter("github.com/chatty/dependency", WarnLevel) silences the debug and
+// info records of that dependency, while keeping its warnings.
+// The records logged by Logger.Log() have no function, so they are never dropped.
+func PackageFilter(pkgPath string, level Level) Filter {
+	return &packageFilter{pkgPath: pkgPath, level: level}
+}
+
+// Filter implements Filter.
+func (f *packageFilter) Filter(r *Record) bool {
+	if r.level >= f.level || r.pc == 0 {
+		return true
+	}
+	pkgPath, _ := splitFunctionName(r.Function())
+	if !strings.HasPrefix(pkgPath, f.pkgPath) {
+		return true
+	}
+	return len(pkgPath) > len(f.pkgPath) && pkgPath[len(f.pkgPath)] != '/' // another package with the same prefix
+}
+
+// NeedsCaller returns true, since the function is needed to filter the records.
+func (f *packageFilter) NeedsCaller() bool {
+	return true
+}
+
+// MessageFilter returns a Filter which drops the records whose messages contain substr.
+func MessageFilter(substr string) Filter {
+	return FilterFunc(func(r *Record) bool {
+		return !strings.Contains(r.Message(), substr)
+	})
+}
+
+// RegexpFilter returns a Filter which drops the records whose messages match re.
+func RegexpFilter(re *regexp.Regexp) Filter {
+	return FilterFunc(func(r *Record) bool {
+		return !re.MatchString(r.Message())
+	})
+}
+
+// filterNeedsCaller reports whether the filter needs the source of the records.
+func filterNeedsCaller(f Filter) bool {
+	cf, ok := f.(interface{ NeedsCaller() bool })
+	return ok && cf.NeedsCaller()
+}
+
+// filterRecord reports whether the record passes all the filters.
+func filterRecord(filters []Filter, r *Record) bool {
+	for _, f := range filters {
+		if !f.Filter(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package golog
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRecordAccessors(t *testing.T) {
+	r := &Record{level: WarnLevel, file: "/src/main.go", line: 10, message: "%s=%d", args: []interface{}{"a", 1}}
+	if r.Level() != WarnLevel || r.File() != "/src/main.go" || r.Line() != 10 || len(r.Args()) != 2 {
+		t.Errorf("record is %+v", r)
+	}
+	if msg := r.Message(); msg != "a=1" {
+		t.Errorf("message is %q", msg)
+	}
+
+	r = &Record{args: []interface{}{1, 2}}
+	if msg := r.Message(); msg != "1 2" {
+		t.Errorf("message is %q", msg)
+	}
+
+	r = &Record{message: "plain"}
+	if msg := r.Message(); msg != "plain" {
+		t.Errorf("message is %q", msg)
+	}
+
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 6, time.Local)
+	r = &Record{tm: tm}
+	if !r.Time().Equal(tm) {
+		t.Errorf("time is %v", r.Time())
+	}
+	r = &Record{date: "2024-01-02", time: "03:04:05"} // from the fast timer
+	if !r.Time().Equal(tm.Truncate(time.Second)) {
+		t.Errorf("time is %v", r.Time())
+	}
+
+	r = &Record{pc: CallerPC(0)}
+	if f := r.Function(); f != "github.com/keakon/golog.TestRecordAccessors" {
+		t.Errorf("function is %q", f)
+	}
+	if f := (&Record{}).Function(); f != "" {
+		t.Errorf("function is %q", f)
+	}
+}
+
+func TestFilters(t *testing.T) {
+	const file = "/go/pkg/mod/github.com/chatty/dependency@v1.0.0/client.go"
+	records := []*Record{
+		{level: InfoLevel, file: file, message: "connected"},
+		{level: WarnLevel, file: file, message: "reconnecting"},
+		{level: InfoLevel, file: "/src/main.go", message: "request %d", args: []interface{}{42}},
+		{level: DebugLevel, file: "/src/main.go", message: "health check"},
+	}
+	filters := []struct {
+		name     string
+		filter   Filter
+		expected []bool
+	}{
+		{"file", FileFilter("github.com/chatty/dependency@", WarnLevel), []bool{false, true, true, true}},
+		{"directory", FileFilter("github.com/chatty/dependency/", WarnLevel), []bool{true, true, true, true}}, // the version is in the path
+		{"message", MessageFilter("request 42"), []bool{true, true, false, true}},
+		{"regexp", RegexpFilter(regexp.MustCompile(`^(health|connected)`)), []bool{false, true, true, false}},
+		{"func", FilterFunc(func(r *Record) bool { return r.Level() != WarnLevel }), []bool{true, false, true, true}},
+	}
+	for _, f := range filters {
+		for i, r := range records {
+			if result := f.filter.Filter(r); result != f.expected[i] {
+				t.Errorf("%s filter returned %t for record %d", f.name, result, i)
+			}
+		}
+	}
+
+	if !filterNeedsCaller(FileFilter("", InfoLevel)) {
+		t.Error("FileFilter doesn't need caller")
+	}
+	if !filterNeedsCaller(PackageFilter("", InfoLevel)) {
+		t.Error("PackageFilter doesn't need caller")
+	}
+	if filterNeedsCaller(MessageFilter("")) {
+		t.Error("MessageFilter needs caller")
+	}
+}
+
+func TestLoggerFilter(t *testing.T) {
+	l, _, w := newCaptureLogger("%l %m")
+	if l.NeedsCaller() {
+		t.Error("logger needs caller")
+	}
+
+	l.AddFilter(FileFilter("filter_test.go", WarnLevel))
+	if !l.NeedsCaller() {
+		t.Error("logger with FileFilter doesn't need caller")
+	}
+	l.AddFilter(MessageFilter("secret"))
+
+	l.Info("dropped")
+	l.Warn("kept")
+	l.Errorf("a %s", "secret")
+	if s := w.String(); s != "W kept\n" {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestPackageFilter(t *testing.T) {
+	filters := []struct {
+		pkgPath string
+		dropped bool
+	}{
+		{"github.com/keakon/golog", true},
+		{"github.com/keakon", true},
+		{"github.com/keakon/go", false},
+		{"github.com/keakon/golog/log", false},
+	}
+	for _, f := range filters {
+		l, _, w := newCaptureLogger("%l %m")
+		l.AddFilter(PackageFilter(f.pkgPath, WarnLevel))
+		l.Info("info")
+		l.Warn("warn")
+		l.Log(InfoLevel, "client.go", 1, "log") // no function
+
+		expected := "I info\nW warn\nI log\n"
+		if f.dropped {
+			expected = "W warn\nI log\n"
+		}
+		if s := w.String(); s != expected {
+			t.Errorf("output of %s is %q", f.pkgPath, s)
+		}
+		l.Close()
+	}
+}
+
+func TestHandlerFilter(t *testing.T) {
+	all := &captureWriter{}
+	allHandler := NewHandler(DebugLevel, ParseFormat("%m"))
+	allHandler.AddWriter(all)
+
+	filtered := &captureWriter{}
+	filteredHandler := NewHandler(DebugLevel, ParseFormat("%m"))
+	filteredHandler.AddFilter(RegexpFilter(regexp.MustCompile(`\d+`)))
+	filteredHandler.AddWriter(filtered)
+	if filteredHandler.NeedsCaller() {
+		t.Error("handler needs caller")
+	}
+	filteredHandler.AddFilter(FileFilter("/vendor/", ErrorLevel))
+	if !filteredHandler.NeedsCaller() {
+		t.Error("handler with FileFilter doesn't need caller")
+	}
+
+	l := NewLogger(DebugLevel)
+	l.AddHandler(filteredHandler)
+	l.AddHandler(allHandler) // still handles the records dropped by the other handler's filters
+
+	l.Info("no digit")
+	l.Infof("%d digits", 2)
+	if s := filtered.String(); s != "no digit\n" {
+		t.Errorf("filtered output is %q", s)
+	}
+	if s := all.String(); s != "no digit\n2 digits\n" {
+		t.Errorf("output is %q", s)
+	}
+	if filteredHandler.Handle(&Record{level: InfoLevel, message: "123"}) {
+		t.Error("handled a dropped record")
+	}
+}
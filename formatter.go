@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"unicode/utf8"
 )
 
 var unknownFile = []byte("???")
 
+// maxFormatWidth is the max width and precision of a directive. A directive exceeding it
+// is emitted as a literal.
+const maxFormatWidth = 1024
+
 var (
 	defaultFormat       = "[%l %D %T %s] %m"
 	timedRotatingFormat = "[%l %T %s] %m"
@@ -23,10 +28,13 @@ var (
 type Formatter struct {
 	formatParts []FormatPart
 	fastPath    formatFastPath
-	// needsCaller reports whether the format contains a source directive (%s or %S),
-	// i.e. whether the caller's file and line are actually rendered. Loggers use it
+	// needsCaller reports whether the format contains a source or function directive
+	// (%s, %S, %R, %f, %F or %p), i.e. whether the caller's file and line are actually used. Loggers use it
 	// to skip the relatively expensive Caller() stack walk when no handler needs it.
 	needsCaller bool
+	// rendersStack reports whether the format contains a %t directive. Otherwise the
+	// stack trace of a record is rendered after the formatted record.
+	rendersStack bool
 }
 
 type formatFastPath uint8
@@ -38,8 +46,8 @@ const (
 	formatFastPathNoSource
 )
 
-// NeedsCaller reports whether the formatter renders the source file/line,
-// i.e. whether its format string contains a %s or %S directive.
+// NeedsCaller reports whether the formatter renders the source file/line or function,
+// i.e. whether its format string contains a %s, %S, %R, %f, %F or %p directive.
 func (f *Formatter) NeedsCaller() bool {
 	return f.needsCaller
 }
@@ -62,25 +70,67 @@ Supported format directives:
 
 	%%: %
 	%l: short name of the level
+	%L: long name of the level (e.g. DEBUG)
+	%P: long name of the level, padded with spaces to the width of the longest level name
 	%T: time string (HH:MM:SS)
 	%D: date string (YYYY-mm-DD)
 	%s: source code string (filename:line)
 	%S: full source code string (/path/filename.go:line)
+	%R: source code string relative to its module root (path/filename.go:line), see SetSourceRoot
+	%f: function name (e.g. (*Handler).Handle)
+	%F: fully qualified function name (e.g. github.com/keakon/golog.(*Handler).Handle)
+	%p: package path (e.g. github.com/keakon/golog)
+	%t: stack trace (see Logger.SetStackTraceLevel), rendered before the trailing newline if omitted
+	%i: process ID
+	%h: hostname
+	%e: executable name
+	%g: goroutine ID
+	%n: service name (see SetService)
+	%v: service version (see SetService)
+	%x or %{name}: a custom directive registered by RegisterDirective
+
+A directive can have printf-like modifiers between the '%' and its character:
+
+	%5l: pads to at least 5 characters with spaces on the left
+	%-5l: pads to at least 5 characters with spaces on the right
+	%.40m: truncates to at most 40 characters
+	%-20.20s: pads and truncates to exactly 20 characters
+
+The widths are counted in runes, and at most 1024. A directive with a larger width or
+precision is emitted as a literal.
 */
 func (f *Formatter) Format(r *Record, buf *bytes.Buffer) {
+	start := buf.Len()
+	r.levelStart, r.levelEnd = 0, 0
 	switch f.fastPath {
 	case formatFastPathDefault:
 		writeDefaultFormat(r, buf)
-		return
 	case formatFastPathTimedRotating:
 		writeTimedRotatingFormat(r, buf)
-		return
 	case formatFastPathNoSource:
 		writeNoSourceFormat(r, buf)
-		return
+	default:
+		for _, part := range f.formatParts {
+			part.Format(r, buf)
+		}
 	}
-	for _, part := range f.formatParts {
-		part.Format(r, buf)
+	if r.stack != "" && !f.rendersStack {
+		appendStack(r, buf)
+	}
+	if r.levelEnd > 0 { // relative to the formatted bytes
+		r.levelStart -= start
+		r.levelEnd -= start
+	}
+}
+
+// appendStack renders the stack trace before the trailing newline of the buf.
+func appendStack(r *Record, buf *bytes.Buffer) {
+	if n := buf.Len(); n > 0 && buf.Bytes()[n-1] == '\n' {
+		buf.Truncate(n - 1)
+		buf.WriteString(r.stack)
+		buf.WriteByte('\n')
+	} else {
+		buf.WriteString(r.stack)
 	}
 }
 
@@ -98,8 +148,11 @@ func detectFormatFastPath(format string) formatFastPath {
 }
 
 // findParts iteratively scans the format string and emits FormatParts.
+// A directive is either a character or a "{name}" (see RegisterDirective).
 // Trailing '%' (no directive following) and unknown directives are emitted as
 // literal bytes, matching the historical behaviour.
+// A directive can have printf-like modifiers between the '%' and its character, in
+// which case its FormatPart is wrapped by a WidthFormatPart.
 func (f *Formatter) findParts(format []byte) {
 	for len(format) > 0 {
 		index := bytes.IndexByte(format, '%')
@@ -119,27 +172,194 @@ func (f *Formatter) findParts(format []byte) {
 				f.appendBytes(format[:index])
 			}
 		}
-		switch c := format[index+1]; c {
-		case '%':
+
+		modifiers, n, valid := parseFormatModifiers(format[index+1:])
+		if index+1+n == len(format) { // no directive after the modifiers
+			f.appendBytes(format[index:])
+			return
+		}
+		c := format[index+1+n]
+		var part FormatPart
+		size := 1 // the length of the directive after the modifiers
+		if c == '{' {
+			var name string
+			name, size = parseDirectiveName(format[index+1+n:])
+			if valid && name != "" {
+				part = f.newNamedFormatPart(name)
+			}
+		} else if valid && (c != '%' || n > 0) {
+			part = f.newFormatPart(c)
+		}
+		if c == '%' && n == 0 {
 			f.appendByte('%')
-		case 'l':
-			f.formatParts = append(f.formatParts, &LevelFormatPart{})
-		case 'T':
-			f.formatParts = append(f.formatParts, &TimeFormatPart{})
-		case 'D':
-			f.formatParts = append(f.formatParts, &DateFormatPart{})
-		case 's':
-			f.formatParts = append(f.formatParts, &SourceFormatPart{})
-			f.needsCaller = true
-		case 'S':
-			f.formatParts = append(f.formatParts, &FullSourceFormatPart{})
-			f.needsCaller = true
-		case 'm':
-			f.formatParts = append(f.formatParts, &MessageFormatPart{})
-		default:
-			f.appendBytes([]byte{'%', c})
+		} else if part == nil {
+			f.appendBytes(append([]byte(nil), format[index:index+1+n+size]...))
+		} else if n == 0 {
+			if p, ok := part.(*BytesFormatPart); ok { // merged with the adjacent literals
+				f.appendBytes(p.bytes)
+			} else {
+				f.formatParts = append(f.formatParts, part)
+			}
+		} else {
+			modifiers.part = part
+			f.formatParts = append(f.formatParts, &modifiers)
+		}
+		format = format[index+1+n+size:]
+	}
+}
+
+// newFormatPart creates the FormatPart of the directive character, or returns nil if
+// it's not a directive.
+func (f *Formatter) newFormatPart(c byte) FormatPart {
+	if part := f.newBuiltinFormatPart(c); part != nil {
+		return part
+	}
+	return f.newCustomFormatPart(string(c))
+}
+
+// newBuiltinFormatPart creates the FormatPart of the built-in directive character, or
+// returns nil if it's not a built-in directive.
+func (f *Formatter) newBuiltinFormatPart(c byte) FormatPart {
+	switch c {
+	case 'l':
+		return &LevelFormatPart{}
+	case 'L':
+		return &LongLevelFormatPart{}
+	case 'P':
+		return &LongLevelFormatPart{padded: true}
+	case 'T':
+		return &TimeFormatPart{}
+	case 'D':
+		return &DateFormatPart{}
+	case 's':
+		f.needsCaller = true
+		return &SourceFormatPart{}
+	case 'S':
+		f.needsCaller = true
+		return &FullSourceFormatPart{}
+	case 'R':
+		f.needsCaller = true
+		return &RelativeSourceFormatPart{}
+	case 'f':
+		f.needsCaller = true
+		return &FunctionFormatPart{}
+	case 'F':
+		f.needsCaller = true
+		return &FunctionFormatPart{full: true}
+	case 'p':
+		f.needsCaller = true
+		return &PackageFormatPart{}
+	case 'm':
+		return &MessageFormatPart{}
+	case 't':
+		f.rendersStack = true
+		return &StackFormatPart{}
+	case 'i':
+		return &BytesFormatPart{bytes: []byte(processID)}
+	case 'h':
+		return &BytesFormatPart{bytes: []byte(hostname)}
+	case 'e':
+		return &BytesFormatPart{bytes: []byte(executable)}
+	case 'g':
+		return &GoroutineFormatPart{}
+	case 'n':
+		return &ServiceFormatPart{}
+	case 'v':
+		return &ServiceFormatPart{version: true}
+	default:
+		return nil
+	}
+}
+
+// parseFormatModifiers parses the modifiers of a directive: an optional '-' flag, an
+// optional width and an optional '.' followed by the precision.
+// It returns the modifiers, their length, and whether the width and precision are
+// within maxFormatWidth.
+func parseFormatModifiers(format []byte) (modifiers WidthFormatPart, n int, valid bool) {
+	valid = true
+	modifiers.precision = -1
+	if n < len(format) && format[n] == '-' {
+		modifiers.leftAlign = true
+		n++
+	}
+	modifiers.width, n = parseFormatWidth(format, n, &valid)
+	if n < len(format) && format[n] == '.' {
+		modifiers.precision, n = parseFormatWidth(format, n+1, &valid)
+	}
+	return
+}
+
+// parseFormatWidth parses the digits of a width or precision from format[n:], and
+// returns it and the index after the digits. The valid is set to false if it exceeds
+// maxFormatWidth.
+func parseFormatWidth(format []byte, n int, valid *bool) (width, end int) {
+	for end = n; end < len(format) && format[end] >= '0' && format[end] <= '9'; end++ {
+		if width <= maxFormatWidth { // stops accumulating before overflowing
+			width = width*10 + int(format[end]-'0')
 		}
-		format = format[index+2:]
+	}
+	if width > maxFormatWidth {
+		*valid = false
+	}
+	return
+}
+
+// WidthFormatPart is a FormatPart wrapping the FormatPart of a directive with modifiers,
+// e.g. %-5l, %20s or %.40m.
+type WidthFormatPart struct {
+	part      FormatPart
+	width     int  // the min width in runes, padded with spaces
+	precision int  // the max width in runes, truncated if exceeded; -1 if not limited
+	leftAlign bool // pads on the right instead of the left
+}
+
+// Format writes the output of its part to the buf, truncated to its precision and
+// padded to its width.
+func (p *WidthFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	start := buf.Len()
+	levelMarked := r.levelEnd > 0
+	p.part.Format(r, buf)
+	content := buf.Bytes()[start:]
+
+	count := utf8.RuneCount(content)
+	if p.precision >= 0 && count > p.precision {
+		end := 0
+		for i := 0; i < p.precision; i++ {
+			_, size := utf8.DecodeRune(content[end:])
+			end += size
+		}
+		buf.Truncate(start + end)
+		count = p.precision
+		if !levelMarked && r.levelEnd > buf.Len() { // the level name is truncated
+			r.levelEnd = buf.Len()
+			if r.levelStart >= r.levelEnd {
+				r.levelStart, r.levelEnd = 0, 0
+			}
+		}
+	}
+
+	pad := p.width - count
+	if pad <= 0 {
+		return
+	}
+	if p.leftAlign {
+		for i := 0; i < pad; i++ {
+			buf.WriteByte(' ')
+		}
+		return
+	}
+	length := buf.Len() - start
+	for i := 0; i < pad; i++ {
+		buf.WriteByte(' ')
+	}
+	bs := buf.Bytes()
+	copy(bs[start+pad:], bs[start:start+length])
+	for i := start; i < start+pad; i++ {
+		bs[i] = ' '
+	}
+	if !levelMarked && r.levelEnd > 0 { // the level name is moved by the padding
+		r.levelStart += pad
+		r.levelEnd += pad
 	}
 }
 
@@ -234,12 +454,45 @@ func (p *LevelFormatPart) Format(r *Record, buf *bytes.Buffer) {
 	writeLevel(r, buf)
 }
 
+// LongLevelFormatPart is a FormatPart of the long level name placeholder.
+type LongLevelFormatPart struct {
+	padded bool
+}
+
+// Format writes the long level name of the record to the buf, padded with spaces if
+// its padded is true.
+func (p *LongLevelFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	name := levelLongNames[r.level]
+	if name == "" {
+		name = r.level.String()
+	}
+	start := buf.Len()
+	buf.WriteString(name)
+	markLevel(r, buf, start)
+	if p.padded {
+		for i := len(name); i < levelNameWidth; i++ {
+			buf.WriteByte(' ')
+		}
+	}
+}
+
 func writeLevel(r *Record, buf *bytes.Buffer) {
-	if int(r.level) < len(levelNames) {
-		buf.WriteByte(levelNames[int(r.level)])
+	start := buf.Len()
+	if name := levelShortNames[r.level]; name != "" {
+		buf.WriteString(name)
 	} else {
 		buf.WriteByte('?')
 	}
+	markLevel(r, buf, start)
+}
+
+// markLevel marks buf[start:] as the level name of the record for ConsoleWriter, unless
+// a level name has been rendered before.
+func markLevel(r *Record, buf *bytes.Buffer, start int) {
+	if r.levelEnd == 0 {
+		r.levelStart = start
+		r.levelEnd = buf.Len()
+	}
 }
 
 // TimeFormatPart is a FormatPart of the time placeholder.
@@ -374,6 +627,53 @@ func writeFullSource(r *Record, buf *bytes.Buffer) {
 	}
 }
 
+// RelativeSourceFormatPart is a FormatPart of the relative source code placeholder.
+type RelativeSourceFormatPart struct{}
+
+// Format writes the source file path relative to its module root and line number of
+// the record to the buf.
+func (p *RelativeSourceFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	if r.line > 0 {
+		buf.WriteString(relativeSource(r.file, r.Function()))
+		buf.WriteByte(':')
+		writeUintToBuf(buf, r.line)
+	} else {
+		buf.Write(unknownFile)
+	}
+}
+
+// FunctionFormatPart is a FormatPart of the function name placeholder.
+type FunctionFormatPart struct {
+	full bool
+}
+
+// Format writes the function name (fully qualified if its full is true) of the record
+// to the buf.
+func (p *FunctionFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	name := r.Function()
+	if name == "" {
+		buf.Write(unknownFile)
+		return
+	}
+	if !p.full {
+		_, name = splitFunctionName(name)
+	}
+	buf.WriteString(name)
+}
+
+// PackageFormatPart is a FormatPart of the package path placeholder.
+type PackageFormatPart struct{}
+
+// Format writes the package path of the record to the buf.
+func (p *PackageFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	pkgPath, _ := splitFunctionName(r.Function())
+	if pkgPath == "" {
+		buf.Write(unknownFile)
+		return
+	}
+	buf.WriteString(pkgPath)
+}
+
 // MessageFormatPart is a FormatPart of the message placeholder.
 type MessageFormatPart struct{}
 
@@ -382,6 +682,14 @@ func (p *MessageFormatPart) Format(r *Record, buf *bytes.Buffer) {
 	writeMessage(r, buf)
 }
 
+// StackFormatPart is a FormatPart of the stack trace placeholder.
+type StackFormatPart struct{}
+
+// Format writes the stack trace of the record to the buf.
+func (p *StackFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	buf.WriteString(r.stack)
+}
+
 func writeMessage(r *Record, buf *bytes.Buffer) {
 	if len(r.args) > 0 {
 		if r.message == "" {
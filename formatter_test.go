@@ -5,6 +5,7 @@ import (
 	"reflect"
 	"testing"
 	"time"
+	"unicode/utf8"
 )
 
 func TestParseFormat(t *testing.T) {
@@ -282,7 +283,7 @@ func TestBytesFormatPart(t *testing.T) {
 }
 
 func TestLevelFormatPart(t *testing.T) {
-	r := &Record{}
+	r := &Record{level: DebugLevel}
 	buf := &bytes.Buffer{}
 	part := LevelFormatPart{}
 	part.Format(r, buf)
@@ -440,3 +441,87 @@ func TestMessageFormatPart(t *testing.T) {
 		t.Error()
 	}
 }
+
+func TestWidthFormatPart(t *testing.T) {
+	r := &Record{
+		level:   InfoLevel,
+		file:    "/path/to/source_file.go",
+		line:    123,
+		message: "héllo world",
+	}
+	for format, expected := range map[string]string{
+		"%5l|":       "    I|\n",
+		"%-5L|":      "INFO |\n",
+		"%-5.3L|":    "INF  |\n",
+		"%20s|":      "     source_file:123|\n",
+		"%.5m|":      "héllo|\n",
+		"%-12.7m|":   "héllo w     |\n",
+		"%.0m|":      "|\n",
+		"%3m|":       "héllo world|\n",
+		"%-5x|%-5%":  "%-5x|%-5%\n",
+		"%12.2m|%-":  "          hé|%-\n",
+		"[%-6P%.2L]": "[INFO  IN]\n",
+
+		"%99999999999m|":          "%99999999999m|\n", // out of range
+		"%.99999999999999999999m": "%.99999999999999999999m\n",
+		"%-1025{m}|":              "%-1025{m}|\n",
+	} {
+		buf := &bytes.Buffer{}
+		ParseFormat(format).Format(r, buf)
+		if s := buf.String(); s != expected {
+			t.Errorf("format %q output %q, expected %q", format, s, expected)
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	ParseFormat("%1024m").Format(r, buf)
+	if n := utf8.RuneCount(buf.Bytes()); n != 1025 { // with the trailing newline
+		t.Errorf("output length is %d, expected 1025", n)
+	}
+	if ParseFormat("%.1025s").NeedsCaller() {
+		t.Error("out of range source directive needs the caller")
+	}
+
+	f := ParseFormat("%-20s %m")
+	if !f.NeedsCaller() {
+		t.Error("source directive with modifiers doesn't need the caller")
+	}
+	if _, ok := f.formatParts[0].(*WidthFormatPart); !ok {
+		t.Errorf("part is %T", f.formatParts[0])
+	}
+}
+
+func TestFunctionFormatPart(t *testing.T) {
+	l, _, w := newCaptureLogger("%f|%F|%p|%m")
+
+	l.Info("method")
+	func() {
+		l.Info("closure")
+	}()
+	first, second := func() { l.Info("first") }, func() { l.Info("second") } // on the same line
+	first()
+	second()
+	l.Log(InfoLevel, "unknown.go", 1, "unknown")
+	expected := "TestFunctionFormatPart|github.com/keakon/golog.TestFunctionFormatPart|github.com/keakon/golog|method\n" +
+		"TestFunctionFormatPart.func1|github.com/keakon/golog.TestFunctionFormatPart.func1|github.com/keakon/golog|closure\n" +
+		"TestFunctionFormatPart.func2|github.com/keakon/golog.TestFunctionFormatPart.func2|github.com/keakon/golog|first\n" +
+		"TestFunctionFormatPart.func3|github.com/keakon/golog.TestFunctionFormatPart.func3|github.com/keakon/golog|second\n" +
+		"???|???|???|unknown\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestSplitFunctionName(t *testing.T) {
+	for name, expected := range map[string][2]string{
+		"github.com/keakon/golog.(*Logger).Log": {"github.com/keakon/golog", "(*Logger).Log"},
+		"main.main":                             {"main", "main"},
+		"main.main.func1":                       {"main", "main.func1"},
+		"gopkg.in/yaml%2ev3.(*Decoder).Decode":  {"gopkg.in/yaml.v3", "(*Decoder).Decode"},
+		"noPackage":                             {"", "noPackage"},
+	} {
+		if pkgPath, function := splitFunctionName(name); pkgPath != expected[0] || function != expected[1] {
+			t.Errorf("split %q into %q, %q", name, pkgPath, function)
+		}
+	}
+}
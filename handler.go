@@ -20,12 +20,28 @@ var bufPool = sync.Pool{
 	},
 }
 
+// A RecordWriter is a writer which also needs the record of the formatted bytes,
+// e.g. to map its level to a protocol field.
+// Handler calls its WriteRecord() instead of Write().
+type RecordWriter interface {
+	io.WriteCloser
+	WriteRecord(r *Record, p []byte) (n int, err error)
+}
+
 // A Handler is a leveled log handler with a formatter and several writers.
 type Handler struct {
-	writers    []io.WriteCloser
-	formatter  *Formatter
-	level      Level
-	isInternal bool
+	writers           []io.WriteCloser
+	filters           []Filter
+	formatter         *Formatter
+	level             Level
+	maxLevel          Level // the highest acceptable level if hasMaxLevel
+	hasMaxLevel       bool
+	isInternal        bool
+	hasRecordWriters  bool // whether any writer is a RecordWriter
+	writersNeedCaller bool // whether any writer or filter needs the source without rendering it
+	sampler           *sampler
+	limiter           *rateLimiter
+	collapser         *collapser
 }
 
 // NewHandler creates a new Handler of the given level with the formatter.
@@ -40,41 +56,154 @@ func NewHandler(level Level, formatter *Formatter) *Handler {
 	}
 }
 
+// NewLevelRangeHandler creates a new Handler accepting the records of minLevel..maxLevel
+// (inclusive) with the formatter.
+// e.g. a handler of DebugLevel..InfoLevel won't handle the warning records.
+func NewLevelRangeHandler(minLevel, maxLevel Level, formatter *Formatter) *Handler {
+	if formatter == nil {
+		formatter = DefaultFormatter
+	}
+	return &Handler{
+		level:       minLevel,
+		maxLevel:    maxLevel,
+		hasMaxLevel: true,
+		formatter:   formatter,
+	}
+}
+
 // AddWriter adds a writer to the Handler.
 // The Write() method of the writer should be thread-safe.
+// If the writer is a RecordWriter, its WriteRecord() method will be called instead.
+// If the writer has a NeedsCaller() method returning true (e.g. JournalWriter), the
+// source of the records will be computed even if the formatter doesn't render it;
+// such a writer should be added before adding the handler to a logger.
 func (h *Handler) AddWriter(w io.WriteCloser) {
 	h.writers = append(h.writers, w)
+	if _, ok := w.(RecordWriter); ok {
+		h.hasRecordWriters = true
+	}
+	if cw, ok := w.(interface{ NeedsCaller() bool }); ok && cw.NeedsCaller() {
+		h.writersNeedCaller = true
+	}
+}
+
+// AddFilter adds a Filter to the Handler, which is checked after its level.
+// It should be added before adding the handler to a logger.
+func (h *Handler) AddFilter(f Filter) {
+	h.filters = append(h.filters, f)
+	if filterNeedsCaller(f) {
+		h.writersNeedCaller = true
+	}
+}
+
+// NeedsCaller reports whether the handler needs the source file and line of the
+// records, i.e. whether its formatter renders them or any of its writers or filters
+// needs them.
+// A nil formatter is treated conservatively as needing the caller.
+func (h *Handler) NeedsCaller() bool {
+	return h.formatter == nil || h.formatter.needsCaller || h.writersNeedCaller ||
+		(h.sampler != nil && h.sampler.key == SampleByCallSite)
 }
 
 // Handle formats a record using its formatter, then writes the formatted result to all of its writers.
-// Returns true if it can handle the record.
+// Returns true if it can handle the record, i.e. the record is in its level range and
+// passes its filters.
 // The errors during writing will be logged by the internalLogger.
 // It's not thread-safe, concurrent record may be written in a random order through different writers.
 // But two records won't be mixed in a single line.
 func (h *Handler) Handle(r *Record) bool {
-	if r.level >= h.level {
-		buf := bufPool.Get().(*bytes.Buffer)
-		buf.Reset()
-		h.formatter.Format(r, buf)
-		content := buf.Bytes()
-		for _, w := range h.writers {
-			_, err := w.Write(content)
-			if err != nil && !h.isInternal {
-				logError(err)
-			}
+	if r.level >= h.level && (!h.hasMaxLevel || r.level <= h.maxLevel) {
+		if len(h.filters) > 0 && !filterRecord(h.filters, r) {
+			return false
+		}
+		if h.collapser != nil && !h.collapser.collapse(h, r) {
+			return false
+		}
+		if h.sampler != nil && !h.sampler.sample(h, r) {
+			return false
 		}
-		if buf.Cap() <= maxPooledBufSize {
-			bufPool.Put(buf)
+		if h.limiter != nil && !h.limiter.allow(h, r) {
+			return false
 		}
+		h.output(r)
 		return true
 	}
 	return false
 }
 
+// output formats a record, then writes the formatted result to all of its writers.
+func (h *Handler) output(r *Record) {
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	h.formatter.Format(r, buf)
+	content := buf.Bytes()
+	for _, w := range h.writers {
+		err := h.write(w, r, content)
+		if err != nil && !h.isInternal {
+			logError(err)
+		}
+	}
+	if buf.Cap() <= maxPooledBufSize {
+		bufPool.Put(buf)
+	}
+}
+
+// outputSummary outputs a record reporting the records dropped by the handler,
+// bypassing its level range, filters, duplicate collapsing, sampling and rate limits.
+func (h *Handler) outputSummary(lv Level, file string, line int, msg string, args ...interface{}) {
+	r := recordPool.Get().(*Record)
+	r.level = lv
+	r.stamp()
+	r.file = file
+	r.line = line
+	r.pc = 0
+	r.message = msg
+	r.args = args
+	h.output(r)
+	r.message = ""
+	r.file = ""
+	r.args = nil
+	recordPool.Put(r)
+}
+
+// write writes the formatted content of the record to the writer.
+func (h *Handler) write(w io.WriteCloser, r *Record, content []byte) (err error) {
+	if h.hasRecordWriters {
+		if rw, ok := w.(RecordWriter); ok {
+			_, err = rw.WriteRecord(r, content)
+			return
+		}
+	}
+	_, err = w.Write(content)
+	return
+}
+
+// Flush flushes its writers having a Flush() error method (e.g. BufferedFileWriter),
+// without closing them.
+// The errors during flushing will be logged by the internalLogger.
+func (h *Handler) Flush() {
+	for _, w := range h.writers {
+		if f, ok := w.(interface{ Flush() error }); ok {
+			if err := f.Flush(); err != nil {
+				logError(err)
+			}
+		}
+	}
+}
+
 // Close closes all its writers.
 // It's safe to call this method more than once,
 // but it's unsafe to call its writers' Close() more than once.
 func (h *Handler) Close() {
+	if h.collapser != nil {
+		h.collapser.stop(h)
+	}
+	if h.sampler != nil {
+		h.sampler.stop(h)
+	}
+	if h.limiter != nil {
+		h.limiter.stop(h)
+	}
 	for _, w := range h.writers {
 		err := w.Close()
 		if err != nil {
@@ -3,17 +3,64 @@ package golog
 import (
 	"bytes"
 	"strings"
+	"sync"
 	"testing"
 )
 
 // captureWriter records everything written to it so a test can assert the exact
-// bytes a handler produced.
+// bytes a handler produced. It's guarded by a lock, since the summary records of the
+// sampling, rate limits and duplicate collapsing are written by timers.
 type captureWriter struct {
-	bytes.Buffer
+	lock   sync.Mutex
+	buf    bytes.Buffer
+	levels []Level // the levels of the records written by WriteRecord()
+}
+
+func (w *captureWriter) Write(p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *captureWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.levels = append(w.levels, r.level)
+	return w.buf.Write(p)
+}
+
+func (w *captureWriter) String() string {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.String()
+}
+
+func (w *captureWriter) Len() int {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.buf.Len()
+}
+
+func (w *captureWriter) Reset() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.buf.Reset()
+	w.levels = nil
 }
 
 func (w *captureWriter) Close() error { return nil }
 
+// newCaptureLogger creates a debug level logger with a debug level handler of the
+// format, whose output is captured by the returned writer.
+func newCaptureLogger(format string) (*Logger, *Handler, *captureWriter) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat(format))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	return l, h, w
+}
+
 func TestHandle(t *testing.T) {
 	h := NewHandler(InfoLevel, DefaultFormatter)
 	r := &Record{tm: now()}
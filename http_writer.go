@@ -0,0 +1,470 @@
+package golog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultHTTPBatchSize     = 1024 * 1024
+	defaultHTTPBatchInterval = time.Second
+	defaultHTTPMaxRetries    = 5
+	defaultHTTPRetryLimit    = 1024 * 1024 * 16
+	defaultHTTPMinBackoff    = time.Millisecond * 100
+	defaultHTTPMaxBackoff    = time.Second * 10
+
+	httpTimeout     = time.Second * 10
+	ndjsonMediaType = "application/x-ndjson"
+)
+
+// An HTTPWriter is a RecordWriter which ships the records to an HTTP endpoint in
+// batches. Each batch is POSTed as an NDJSON body (optionally gzipped), one object
+// per record:
+//
+//	{"time":"2006-01-02T15:04:05.999999999+08:00","level":"error","file":"main.go","line":12,"message":"..."}
+//
+// The level, file and line are omitted for the bytes written by Write(). The stack trace
+// of a record is sent in a "stack" field.
+//
+// Write never blocks on the network: a batch is sent by a background goroutine when
+// it reaches the batch size or the batch interval elapses. The batches failed with a
+// network error, a 408, 429 or 5xx status are retried in order with an exponential
+// backoff, up to the max retries. The other failures are dropped. The batches waiting
+// to be sent are bounded by the retry queue limit, beyond which the oldest are dropped.
+type HTTPWriter struct {
+	lock          sync.Mutex
+	url           string
+	client        *http.Client
+	header        http.Header
+	gzip          bool
+	batchSize     int
+	batchInterval time.Duration
+	maxRetries    int
+	retryLimit    int
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	pending       *bytes.Buffer // the records of the next batch, guarded by lock
+	queue         [][]byte      // the batches to send in order, only used by schedule()
+	queueSize     int           // only used by schedule()
+	attempts      int           // the failed attempts of queue[0], only used by schedule()
+	body          bytes.Buffer  // the gzipped body, only used by schedule()
+	gzipWriter    *gzip.Writer  // only used by schedule()
+	batchChan     chan struct{}
+	stopChan      chan struct{}
+	stoppedChan   chan struct{}
+	closeOnce     sync.Once
+	closeErr      error
+	closed        bool
+}
+
+// HTTPWriterOption is an option of HTTPWriter.
+type HTTPWriterOption func(*HTTPWriter)
+
+// HTTPClient sets the client to send the requests.
+// It defaults to a client with a 10 seconds timeout.
+func HTTPClient(client *http.Client) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if client != nil {
+			w.client = client
+		}
+	}
+}
+
+// HTTPHeader adds a header to the requests, e.g. an Authorization header.
+func HTTPHeader(key, value string) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		w.header.Add(key, value)
+	}
+}
+
+// HTTPGzip compresses the request bodies with gzip.
+func HTTPGzip() HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		w.gzip = true
+	}
+}
+
+// HTTPBatchSize sets how many bytes of records triggers sending a batch.
+// It defaults to 1 MB.
+func HTTPBatchSize(size int) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if size > 0 {
+			w.batchSize = size
+		}
+	}
+}
+
+// HTTPBatchInterval sets the max interval to send the buffered records.
+// It defaults to 1 second.
+func HTTPBatchInterval(interval time.Duration) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if interval > 0 {
+			w.batchInterval = interval
+		}
+	}
+}
+
+// HTTPMaxRetries sets how many times a failed batch is retried before dropped.
+// It defaults to 5.
+func HTTPMaxRetries(retries int) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if retries >= 0 {
+			w.maxRetries = retries
+		}
+	}
+}
+
+// HTTPRetryQueueLimit sets how many bytes of batches can wait to be sent.
+// It defaults to 16 MB.
+func HTTPRetryQueueLimit(size int) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if size >= 1024 {
+			w.retryLimit = size
+		}
+	}
+}
+
+// HTTPBackoff sets the min and max delays between retries.
+// They default to 0.1 and 10 seconds.
+func HTTPBackoff(min, max time.Duration) HTTPWriterOption {
+	return func(w *HTTPWriter) {
+		if min > 0 && max >= min {
+			w.minBackoff = min
+			w.maxBackoff = max
+		}
+	}
+}
+
+// NewHTTPWriter creates a new HTTPWriter posting to the url.
+func NewHTTPWriter(url string, options ...HTTPWriterOption) (*HTTPWriter, error) {
+	req, err := http.NewRequest(http.MethodPost, url, nil) // validates the url
+	if err != nil {
+		return nil, err
+	}
+
+	w := &HTTPWriter{
+		url:           req.URL.String(),
+		client:        &http.Client{Timeout: httpTimeout},
+		header:        http.Header{},
+		batchSize:     defaultHTTPBatchSize,
+		batchInterval: defaultHTTPBatchInterval,
+		maxRetries:    defaultHTTPMaxRetries,
+		retryLimit:    defaultHTTPRetryLimit,
+		minBackoff:    defaultHTTPMinBackoff,
+		maxBackoff:    defaultHTTPMaxBackoff,
+		pending:       &bytes.Buffer{},
+		batchChan:     make(chan struct{}, 1),
+		stopChan:      make(chan struct{}),
+		stoppedChan:   make(chan struct{}),
+	}
+	for _, option := range options {
+		option(w)
+	}
+	if w.gzip {
+		w.gzipWriter = gzip.NewWriter(&w.body)
+	}
+
+	go w.schedule()
+	return w, nil
+}
+
+// Write appends p as a record with only the time and message to the batch.
+func (w *HTTPWriter) Write(p []byte) (n int, err error) {
+	return w.write(nil, p)
+}
+
+// WriteRecord appends p as a record with the level and source of r to the batch.
+func (w *HTTPWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	return w.write(r, p)
+}
+
+func (w *HTTPWriter) write(r *Record, p []byte) (n int, err error) {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return 0, os.ErrClosed
+	}
+
+	buf := w.pending
+	buf.WriteString(`{"time":"`)
+	buf.WriteString(now().Format(time.RFC3339Nano))
+	buf.WriteByte('"')
+	if r != nil {
+		if name := levelLowerNames[r.level]; name != "" {
+			buf.WriteString(`,"level":`)
+			writeJSONString(buf, []byte(name))
+		}
+		if r.line > 0 {
+			buf.WriteString(`,"file":`)
+			writeJSONString(buf, []byte(r.file))
+			buf.WriteString(`,"line":`)
+			writeUintToBuf(buf, r.line)
+		}
+	}
+	n = len(p) // the whole p is consumed, though the trailing newline is trimmed
+	p = bytes.TrimSuffix(p, []byte{'\n'})
+	if r != nil && r.stack != "" {
+		p = bytes.TrimSuffix(p, []byte(r.stack)) // it's rendered at the end of p if the format has no %t
+	}
+	buf.WriteString(`,"message":`)
+	writeJSONString(buf, p)
+	if r != nil && r.stack != "" {
+		buf.WriteString(`,"stack":`)
+		writeJSONString(buf, []byte(strings.TrimPrefix(r.stack, "\n")))
+	}
+	buf.WriteString("}\n")
+	full := buf.Len() >= w.batchSize
+	w.lock.Unlock()
+
+	if full {
+		select { // ignores if blocked
+		case w.batchChan <- struct{}{}:
+		default:
+		}
+	}
+	return n, nil
+}
+
+// NeedsCaller returns true, so that the file and line are sent even if the formatter
+// doesn't render the source.
+func (w *HTTPWriter) NeedsCaller() bool {
+	return true
+}
+
+// writeJSONString writes s as a JSON string. Invalid UTF-8 bytes are replaced by U+FFFD.
+func writeJSONString(buf *bytes.Buffer, s []byte) {
+	const hex = "0123456789abcdef"
+
+	buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRune(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				buf.Write(s[start:i])
+				buf.WriteString("\ufffd")
+				i++
+				start = i
+				continue
+			}
+			i += size
+			continue
+		}
+		if c >= 0x20 && c != '"' && c != '\\' {
+			i++
+			continue
+		}
+
+		buf.Write(s[start:i])
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			buf.WriteString(`\u00`)
+			buf.WriteByte(hex[c>>4])
+			buf.WriteByte(hex[c&0xF])
+		}
+		i++
+		start = i
+	}
+	buf.Write(s[start:])
+	buf.WriteByte('"')
+}
+
+// schedule runs in its own goroutine, cutting the batches and sending them with an
+// exponential backoff after a failure.
+func (w *HTTPWriter) schedule() {
+	ticker := time.NewTicker(w.batchInterval)
+	retryTimer := time.NewTimer(w.minBackoff)
+	stopTimer(retryTimer) // start dormant; only fire after a failure
+	backoff := w.minBackoff
+	waiting := false
+
+	for {
+		select {
+		case <-w.batchChan:
+		case <-ticker.C:
+		case <-retryTimer.C:
+			waiting = false
+		case <-w.stopChan:
+			ticker.Stop()
+			stopTimer(retryTimer)
+			w.cutBatch()
+			for len(w.queue) > 0 { // tries once more without retrying
+				if err := w.sendBatch(); err != nil {
+					logError(err)
+					break
+				}
+				w.popBatch()
+			}
+			close(w.stoppedChan)
+			return
+		}
+
+		w.cutBatch()
+		if waiting { // the retry timer will send them
+			continue
+		}
+		if w.sendQueue() {
+			backoff = w.minBackoff
+		} else {
+			waiting = true
+			retryTimer.Reset(backoff)
+			backoff *= 2
+			if backoff > w.maxBackoff {
+				backoff = w.maxBackoff
+			}
+		}
+	}
+}
+
+// cutBatch moves the pending records to the end of the queue as a new batch, then
+// drops the oldest batches exceeding the retry queue limit.
+// It should only be called by schedule().
+func (w *HTTPWriter) cutBatch() {
+	w.lock.Lock()
+	if w.pending.Len() == 0 {
+		w.lock.Unlock()
+		return
+	}
+	batch := w.pending.Bytes()
+	w.pending = &bytes.Buffer{}
+	w.lock.Unlock()
+
+	w.queue = append(w.queue, batch)
+	w.queueSize += len(batch)
+	dropped := 0
+	for w.queueSize > w.retryLimit && len(w.queue) > 1 {
+		dropped += len(w.queue[0])
+		w.popBatch()
+	}
+	if dropped > 0 {
+		logError(fmt.Errorf("dropped %d bytes exceeding the retry queue limit of %s", dropped, w.url))
+	}
+}
+
+// popBatch removes the first batch of the queue.
+// It should only be called by schedule().
+func (w *HTTPWriter) popBatch() {
+	w.queueSize -= len(w.queue[0])
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	w.attempts = 0
+}
+
+// sendQueue sends the queued batches in order, and reports whether all of them were
+// sent or dropped. It stops at the first retryable failure.
+// It should only be called by schedule().
+func (w *HTTPWriter) sendQueue() bool {
+	for len(w.queue) > 0 {
+		err := w.sendBatch()
+		if err == nil {
+			w.popBatch()
+			continue
+		}
+
+		if retryable, ok := err.(*httpRetryableError); ok {
+			if w.attempts < w.maxRetries {
+				w.attempts++
+				if w.attempts == 1 { // logs once per batch
+					logError(err)
+				}
+				return false
+			}
+			err = retryable.err
+		}
+		logError(fmt.Errorf("dropped a batch of %d bytes: %w", len(w.queue[0]), err))
+		w.popBatch()
+	}
+	return true
+}
+
+// httpRetryableError wraps an error which can be resolved by retrying.
+type httpRetryableError struct {
+	err error
+}
+
+func (e *httpRetryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpRetryableError) Unwrap() error {
+	return e.err
+}
+
+// sendBatch posts the first batch of the queue.
+// It should only be called by schedule().
+func (w *HTTPWriter) sendBatch() error {
+	body := w.queue[0]
+	if w.gzip {
+		w.body.Reset()
+		w.gzipWriter.Reset(&w.body)
+		w.gzipWriter.Write(body) // writing to a bytes.Buffer never fails
+		w.gzipWriter.Close()
+		body = w.body.Bytes()
+	}
+
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range w.header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", ndjsonMediaType)
+	if w.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return &httpRetryableError{err}
+	}
+	io.Copy(io.Discard, resp.Body) // lets the connection be reused
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("%s responded %s", w.url, resp.Status)
+	if resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return &httpRetryableError{err}
+	}
+	return err
+}
+
+// Close sends the buffered records and the queued batches (without retrying), and
+// returns an error reporting how many bytes were not sent.
+// It's safe to call this method more than once.
+func (w *HTTPWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.lock.Lock()
+		w.closed = true
+		w.lock.Unlock()
+		close(w.stopChan)
+		<-w.stoppedChan // waits for schedule() to finish its last sending
+
+		if w.queueSize > 0 {
+			w.closeErr = fmt.Errorf("%d bytes were not sent to %s", w.queueSize, w.url)
+		}
+		w.queue = nil
+		w.pending = nil
+	})
+	return w.closeErr
+}
@@ -0,0 +1,278 @@
+package golog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type httpTestRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+	Stack   string `json:"stack"`
+}
+
+// httpTestServer records the batches it received, responding with the statuses in order
+// (then 200).
+type httpTestServer struct {
+	*httptest.Server
+	lock     sync.Mutex
+	t        *testing.T
+	statuses []int
+	requests int
+	batches  [][]httpTestRecord
+	header   http.Header
+	received chan struct{}
+}
+
+func newHTTPTestServer(t *testing.T, statuses ...int) *httpTestServer {
+	s := &httpTestServer{t: t, statuses: statuses, received: make(chan struct{}, 100)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *httpTestServer) serve(rw http.ResponseWriter, req *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.requests++
+	if len(s.statuses) > 0 {
+		status := s.statuses[0]
+		s.statuses = s.statuses[1:]
+		if status != http.StatusOK {
+			rw.WriteHeader(status)
+			return
+		}
+	}
+
+	s.header = req.Header
+	var body io.Reader = req.Body
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(req.Body)
+		if err != nil {
+			s.t.Error(err)
+			return
+		}
+		body = reader
+	}
+	var batch []httpTestRecord
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		var record httpTestRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			s.t.Errorf("invalid record %q: %v", scanner.Bytes(), err)
+		}
+		batch = append(batch, record)
+	}
+	s.batches = append(s.batches, batch)
+	s.received <- struct{}{}
+}
+
+func (s *httpTestServer) wait(t *testing.T) {
+	t.Helper()
+
+	select {
+	case <-s.received:
+	case <-time.After(time.Second * 5):
+		t.Fatal("no batch was received")
+	}
+}
+
+func (s *httpTestServer) messages() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var messages []string
+	for _, batch := range s.batches {
+		for _, record := range batch {
+			messages = append(messages, record.Message)
+		}
+	}
+	return messages
+}
+
+func TestWriteJSONString(t *testing.T) {
+	for _, s := range []string{"", "plain", `"quoted" \ back`, "multi\nline\r\t", "\x00\x1f\x7f", "中文", "invalid\xff\xfe utf-8"} {
+		var buf bytes.Buffer
+		writeJSONString(&buf, []byte(s))
+		var result string
+		if err := json.Unmarshal(buf.Bytes(), &result); err != nil {
+			t.Errorf("invalid JSON string %q: %v", buf.Bytes(), err)
+			continue
+		}
+		expected, _ := json.Marshal(s) // also replaces each invalid byte by U+FFFD
+		var expectedResult string
+		json.Unmarshal(expected, &expectedResult)
+		if result != expectedResult {
+			t.Errorf("decoded %q, expected %q", result, expectedResult)
+		}
+	}
+}
+
+func TestHTTPWriterBatching(t *testing.T) {
+	s := newHTTPTestServer(t)
+	w, err := NewHTTPWriter(s.URL, HTTPBatchSize(1024), HTTPBatchInterval(time.Hour), HTTPHeader("Authorization", "Bearer token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	const count = 20
+	for i := 0; i < count; i++ {
+		l.Warnf("record %d", i)
+	}
+	if n, err := w.Write([]byte("plain\n")); n != 6 || err != nil {
+		t.Errorf("Write() returned %d, %v", n, err)
+	}
+	s.wait(t) // the batch reached the batch size
+
+	s.lock.Lock()
+	if len(s.batches) != 1 {
+		t.Errorf("received %d batches", len(s.batches))
+	} else {
+		record := s.batches[0][0]
+		if record.Level != "warn" || record.Message != "record 0" || record.Line == 0 || !strings.HasSuffix(record.File, "http_writer_test.go") {
+			t.Errorf("record is %+v", record)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, record.Time); err != nil {
+			t.Errorf("invalid time: %v", err)
+		}
+	}
+	if s.header.Get("Content-Type") != ndjsonMediaType || s.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("header is %v", s.header)
+	}
+	s.lock.Unlock()
+
+	l.Close() // sends the rest
+	messages := s.messages()
+	if len(messages) != count+1 {
+		t.Fatalf("received %d records", len(messages))
+	}
+	for i, message := range messages[:count] {
+		if message != "record "+strconv.Itoa(i) {
+			t.Fatalf("record %d is %q", i, message)
+		}
+	}
+	if messages[count] != "plain" {
+		t.Errorf("last record is %q", messages[count])
+	}
+	s.lock.Lock()
+	last := s.batches[len(s.batches)-1]
+	if record := last[len(last)-1]; record.Level != "" || record.Line != 0 {
+		t.Errorf("record written by Write() is %+v", record)
+	}
+	s.lock.Unlock()
+
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHTTPWriterGzipInterval(t *testing.T) {
+	s := newHTTPTestServer(t)
+	w, err := NewHTTPWriter(s.URL, HTTPGzip(), HTTPBatchInterval(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("compressed\n"))
+	s.wait(t) // the batch interval elapsed
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.header.Get("Content-Encoding") != "gzip" {
+		t.Errorf("header is %v", s.header)
+	}
+	if len(s.batches) != 1 || len(s.batches[0]) != 1 || s.batches[0][0].Message != "compressed" {
+		t.Errorf("received %+v", s.batches)
+	}
+}
+
+func TestHTTPWriterRetry(t *testing.T) {
+	s := newHTTPTestServer(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	w, err := NewHTTPWriter(s.URL, HTTPBatchInterval(time.Millisecond*10), HTTPBackoff(time.Millisecond, time.Millisecond*5))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w.Write([]byte("1\n"))
+	w.Write([]byte("2\n"))
+	s.wait(t)
+	w.Write([]byte("3\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if messages := s.messages(); strings.Join(messages, ",") != "1,2,3" {
+		t.Errorf("received %q", messages)
+	}
+	s.lock.Lock()
+	if s.requests != 4 {
+		t.Errorf("sent %d requests, expected 4", s.requests)
+	}
+	s.lock.Unlock()
+}
+
+func TestHTTPWriterDrop(t *testing.T) {
+	// a client error is not retried
+	s := newHTTPTestServer(t, http.StatusBadRequest)
+	w, err := NewHTTPWriter(s.URL, HTTPBatchInterval(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("bad\n"))
+	time.Sleep(time.Millisecond * 100)
+	w.Write([]byte("good\n"))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if messages := s.messages(); len(messages) != 1 || messages[0] != "good" {
+		t.Errorf("received %q", messages)
+	}
+
+	// the oldest batches exceeding the retry queue limit are dropped
+	statuses := make([]int, 1000)
+	for i := range statuses {
+		statuses[i] = http.StatusInternalServerError
+	}
+	s = newHTTPTestServer(t, statuses...)
+	w, err = NewHTTPWriter(s.URL, HTTPBatchSize(100), HTTPRetryQueueLimit(1024), HTTPBackoff(time.Hour, time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := []byte(strings.Repeat("x", 100) + "\n")
+	for i := 0; i < 100; i++ {
+		w.Write(record)
+		time.Sleep(time.Millisecond)
+	}
+	err = w.Close()
+	if err == nil || !strings.Contains(err.Error(), "bytes were not sent") {
+		t.Fatalf("Close() error is %v", err)
+	}
+	unsent, _ := strconv.Atoi(strings.Fields(err.Error())[0])
+	if unsent == 0 || unsent > 1024+len(record)*2 {
+		t.Errorf("%d bytes were kept", unsent)
+	}
+}
@@ -0,0 +1,217 @@
+package golog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const defaultJournalSocket = "/run/systemd/journal/socket"
+
+// A JournalWriter is a RecordWriter which sends the records to systemd-journald
+// through its native protocol.
+//
+// Each record becomes a journal entry with these fields:
+//
+//	MESSAGE: the formatted record without the trailing newline
+//	PRIORITY: the syslog severity mapped from its level
+//	GOLOG_LEVEL: the long name of its level, which keeps the custom levels apart
+//	CODE_FILE, CODE_LINE: its source
+//	CODE_FUNC: its function, if known
+//	SYSLOG_IDENTIFIER: the base name of the executable, or set by JournalIdentifier
+//
+// plus the fields set by JournalField and JournalRecordField. The entries too large
+// for a datagram are passed through a sealed memfd, or an unlinked temporary file if
+// memfd is not supported (only on Linux).
+type JournalWriter struct {
+	lock         sync.Mutex
+	conn         *net.UnixConn
+	buf          bytes.Buffer
+	socket       string
+	identifier   string
+	fields       []byte // the encoded fields added to every entry
+	recordFields []journalRecordField
+	closed       bool
+}
+
+// JournalOption is an option of JournalWriter.
+type JournalOption func(*JournalWriter)
+
+// JournalSocket sets the path of journald's native socket.
+// It defaults to /run/systemd/journal/socket.
+func JournalSocket(path string) JournalOption {
+	return func(w *JournalWriter) {
+		w.socket = path
+	}
+}
+
+// JournalIdentifier sets the SYSLOG_IDENTIFIER field of the entries.
+func JournalIdentifier(identifier string) JournalOption {
+	return func(w *JournalWriter) {
+		w.identifier = identifier
+	}
+}
+
+// JournalField adds a field to every entry. The name is converted to a valid journal
+// field name: uppercase letters, digits and underscores, not starting with an underscore.
+func JournalField(name, value string) JournalOption {
+	return func(w *JournalWriter) {
+		var buf bytes.Buffer
+		writeJournalField(&buf, journalFieldName(name), []byte(value))
+		w.fields = append(w.fields, buf.Bytes()...)
+	}
+}
+
+// A journalRecordField is a field computed from each record.
+type journalRecordField struct {
+	name  string
+	value func(r *Record) string
+}
+
+// JournalRecordField adds a field computed from each record, e.g. a request ID parsed
+// from its args. The field is omitted if the value is empty, or for the bytes written
+// by Write(). The name is converted like JournalField.
+func JournalRecordField(name string, value func(r *Record) string) JournalOption {
+	return func(w *JournalWriter) {
+		if value != nil {
+			w.recordFields = append(w.recordFields, journalRecordField{name: journalFieldName(name), value: value})
+		}
+	}
+}
+
+// NewJournalWriter creates a new JournalWriter connected to journald.
+func NewJournalWriter(options ...JournalOption) (*JournalWriter, error) {
+	w := &JournalWriter{
+		socket:     defaultJournalSocket,
+		identifier: filepath.Base(os.Args[0]),
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: w.socket, Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+	return w, nil
+}
+
+// Write sends p as an entry of the info priority.
+func (w *JournalWriter) Write(p []byte) (n int, err error) {
+	return w.write(nil, p)
+}
+
+// WriteRecord sends p as an entry with the level and source of the record.
+func (w *JournalWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	return w.write(r, p)
+}
+
+func (w *JournalWriter) write(r *Record, p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	w.buf.Reset()
+	writeJournalField(&w.buf, "MESSAGE", bytes.TrimSuffix(p, []byte{'\n'}))
+	w.buf.WriteString("PRIORITY=")
+	if r != nil {
+		writeUintToBuf(&w.buf, syslogSeverity(r.level))
+	} else {
+		writeUintToBuf(&w.buf, syslogInfo)
+	}
+	w.buf.WriteByte('\n')
+	if r != nil {
+		if name := levelLongNames[r.level]; name != "" {
+			writeJournalField(&w.buf, "GOLOG_LEVEL", []byte(name))
+		}
+		if r.line > 0 {
+			writeJournalField(&w.buf, "CODE_FILE", []byte(r.file))
+			w.buf.WriteString("CODE_LINE=")
+			writeUintToBuf(&w.buf, r.line)
+			w.buf.WriteByte('\n')
+			if function := r.Function(); function != "" {
+				writeJournalField(&w.buf, "CODE_FUNC", []byte(function))
+			}
+		}
+	}
+	if w.identifier != "" {
+		writeJournalField(&w.buf, "SYSLOG_IDENTIFIER", []byte(w.identifier))
+	}
+	w.buf.Write(w.fields)
+	if r != nil {
+		for _, field := range w.recordFields {
+			if value := field.value(r); value != "" {
+				writeJournalField(&w.buf, field.name, []byte(value))
+			}
+		}
+	}
+
+	_, err = w.conn.Write(w.buf.Bytes())
+	if err != nil {
+		err = sendLargeJournalEntry(w.conn, w.buf.Bytes(), err)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// NeedsCaller returns true, so that the CODE_FILE and CODE_LINE fields are sent even
+// if the formatter doesn't render the source.
+func (w *JournalWriter) NeedsCaller() bool {
+	return true
+}
+
+// Close closes the connection.
+// It's safe to call this method more than once.
+func (w *JournalWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.conn.Close()
+}
+
+// writeJournalField encodes a field of the native protocol. A value containing newlines
+// is encoded as the name, a newline, its length as a 64-bit little-endian integer, the
+// value and a newline.
+func writeJournalField(buf *bytes.Buffer, name string, value []byte) {
+	buf.WriteString(name)
+	if bytes.IndexByte(value, '\n') < 0 {
+		buf.WriteByte('=')
+	} else {
+		buf.WriteByte('\n')
+		var size [8]byte
+		binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+		buf.Write(size[:])
+	}
+	buf.Write(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName converts name to a valid journal field name.
+func journalFieldName(name string) string {
+	bs := bytes.TrimLeft(bytes.ToUpper([]byte(name)), "_")
+	for i, c := range bs {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			bs[i] = '_'
+		}
+	}
+	if len(bs) == 0 || (bs[0] >= '0' && bs[0] <= '9') {
+		bs = append([]byte{'X'}, bs...)
+	}
+	if len(bs) > 64 {
+		bs = bs[:64]
+	}
+	return string(bs)
+}
@@ -0,0 +1,121 @@
+//go:build linux
+
+package golog
+
+import (
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// The flags of memfd_create() and the seals of fcntl(), which are not defined by the
+// syscall package.
+const (
+	mfdCloexec      = 0x1
+	mfdAllowSealing = 0x2
+
+	fAddSeals = 1033
+
+	fSealSeal   = 0x1
+	fSealShrink = 0x2
+	fSealGrow   = 0x4
+	fSealWrite  = 0x8
+)
+
+// journalTempDirs are the directories where journald accepts unsealed entry files from.
+var journalTempDirs = []string{"/dev/shm", "/tmp"}
+
+// memfdCreate is replaced by tests to exercise the temporary file fallback.
+var memfdCreate = memfdCreateSyscall
+
+// journalSeals are the seals of a memfd entry, so journald can trust its content.
+const journalSeals = fSealShrink | fSealGrow | fSealWrite | fSealSeal
+
+// sendLargeJournalEntry passes an entry too large for a datagram to journald through
+// a sealed memfd, like sd_journal_send() does. It falls back to an unlinked temporary
+// file if memfd_create() is not supported.
+// Other errors are returned as is.
+func sendLargeJournalEntry(conn *net.UnixConn, data []byte, err error) error {
+	if !errors.Is(err, syscall.EMSGSIZE) && !errors.Is(err, syscall.ENOBUFS) {
+		return err
+	}
+
+	f, err := createMemfdJournalEntry(data)
+	if err != nil {
+		if f, err = createTempJournalEntry(data); err != nil {
+			return err
+		}
+	}
+	defer f.Close()
+
+	// WriteMsgUnix() refuses a connected datagram socket, so calls sendmsg directly.
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	rights := syscall.UnixRights(int(f.Fd()))
+	e := rawConn.Write(func(fd uintptr) bool {
+		err = syscall.Sendmsg(int(fd), nil, rights, nil, 0)
+		return err != syscall.EAGAIN
+	})
+	if e != nil {
+		return e
+	}
+	return err
+}
+
+// createMemfdJournalEntry writes the entry to a memfd and seals it.
+func createMemfdJournalEntry(data []byte) (*os.File, error) {
+	fd, err := memfdCreate("golog-journal", mfdCloexec|mfdAllowSealing)
+	if err != nil {
+		return nil, err
+	}
+	f := os.NewFile(uintptr(fd), "golog-journal")
+	if _, err = f.Write(data); err == nil {
+		if _, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fAddSeals, journalSeals); errno != 0 {
+			err = errno
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// memfdCreateSyscall calls memfd_create(), and returns the created file descriptor.
+func memfdCreateSyscall(name string, flags int) (int, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), uintptr(flags), 0)
+	if errno != 0 {
+		return -1, errno
+	}
+	return int(fd), nil
+}
+
+// createTempJournalEntry writes the entry to an unlinked temporary file.
+func createTempJournalEntry(data []byte) (f *os.File, err error) {
+	for _, dir := range journalTempDirs {
+		f, err = os.CreateTemp(dir, "golog-journal-")
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = os.Remove(f.Name()); err == nil {
+		_, err = f.Write(data)
+	}
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,4 @@
+package golog
+
+// sysMemfdCreate is the number of the memfd_create() system call.
+const sysMemfdCreate = 356
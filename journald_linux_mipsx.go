@@ -0,0 +1,6 @@
+//go:build linux && (mips || mipsle)
+
+package golog
+
+// sysMemfdCreate is the number of the memfd_create() system call.
+const sysMemfdCreate = 4354
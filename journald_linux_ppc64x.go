@@ -0,0 +1,6 @@
+//go:build linux && (ppc64 || ppc64le)
+
+package golog
+
+// sysMemfdCreate is the number of the memfd_create() system call.
+const sysMemfdCreate = 360
@@ -0,0 +1,8 @@
+//go:build linux && (arm64 || loong64 || mips64 || mips64le || riscv64 || s390x)
+
+package golog
+
+import "syscall"
+
+// sysMemfdCreate is the number of the memfd_create() system call.
+const sysMemfdCreate = syscall.SYS_MEMFD_CREATE
@@ -0,0 +1,96 @@
+//go:build linux
+
+package golog
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// fGetSeals is the fcntl() command to get the seals of a file.
+const fGetSeals = 1034
+
+func TestJournalWriterLargeEntry(t *testing.T) {
+	t.Run("memfd", func(t *testing.T) {
+		f, data := writeLargeJournalEntry(t)
+		defer f.Close()
+		if path, _ := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd()))); !strings.HasPrefix(path, "/memfd:") {
+			t.Skipf("memfd is not supported, sent %s", path)
+		}
+		if seals, _, errno := syscall.Syscall(syscall.SYS_FCNTL, f.Fd(), fGetSeals, 0); errno != 0 || seals != journalSeals {
+			t.Errorf("seals are %#x, expected %#x", seals, journalSeals)
+		}
+		checkLargeJournalEntry(t, data)
+	})
+
+	t.Run("temporary file", func(t *testing.T) {
+		memfdCreate = func(string, int) (int, error) {
+			return -1, syscall.ENOSYS
+		}
+		defer func() {
+			memfdCreate = memfdCreateSyscall
+		}()
+
+		f, data := writeLargeJournalEntry(t)
+		defer f.Close()
+		if path, _ := os.Readlink("/proc/self/fd/" + strconv.Itoa(int(f.Fd()))); strings.HasPrefix(path, "/memfd:") {
+			t.Errorf("temporary file is %s", path)
+		}
+		checkLargeJournalEntry(t, data)
+	})
+}
+
+// largeJournalMessage is too large for a datagram.
+var largeJournalMessage = strings.Repeat("x", 1024*1024)
+
+// writeLargeJournalEntry writes a large entry, and returns the received file and its
+// content.
+func writeLargeJournalEntry(t *testing.T) (*os.File, []byte) {
+	t.Helper()
+
+	conn, path := listenJournal(t)
+	defer conn.Close()
+
+	w, err := NewJournalWriter(JournalSocket(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	if _, err := w.Write([]byte(largeJournalMessage)); err != nil {
+		t.Fatal(err)
+	}
+
+	oob := make([]byte, syscall.CmsgSpace(4))
+	_, oobn, _, _, err := conn.ReadMsgUnix(nil, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	messages, err := syscall.ParseSocketControlMessage(oob[:oobn])
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("invalid control messages: %v", err)
+	}
+	fds, err := syscall.ParseUnixRights(&messages[0])
+	if err != nil || len(fds) != 1 {
+		t.Fatalf("invalid rights: %v", err)
+	}
+	f := os.NewFile(uintptr(fds[0]), "entry")
+
+	data := make([]byte, len(largeJournalMessage)+1024)
+	n, err := f.ReadAt(data, 0)
+	if n == 0 {
+		f.Close()
+		t.Fatal(err)
+	}
+	return f, data[:n]
+}
+
+func checkLargeJournalEntry(t *testing.T, data []byte) {
+	t.Helper()
+	if fields := parseJournalEntry(t, data); fields["MESSAGE"] != largeJournalMessage {
+		t.Errorf("message length is %d, expected %d", len(fields["MESSAGE"]), len(largeJournalMessage))
+	}
+}
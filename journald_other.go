@@ -0,0 +1,10 @@
+//go:build !linux
+
+package golog
+
+import "net"
+
+// sendLargeJournalEntry returns err as is, since journald only runs on Linux.
+func sendLargeJournalEntry(conn *net.UnixConn, data []byte, err error) error {
+	return err
+}
@@ -0,0 +1,147 @@
+package golog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseJournalEntry decodes the fields of a native protocol entry.
+func parseJournalEntry(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+
+	fields := map[string]string{}
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("invalid entry: %q", data)
+		}
+		name := string(data[:i])
+		if data[i] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[name] = string(data[i+1 : end])
+			data = data[end+1:]
+		} else {
+			size := int(binary.LittleEndian.Uint64(data[i+1:]))
+			start := i + 9
+			fields[name] = string(data[start : start+size])
+			if data[start+size] != '\n' {
+				t.Fatalf("field %s is not terminated by a newline", name)
+			}
+			data = data[start+size+1:]
+		}
+	}
+	return fields
+}
+
+func listenJournal(t *testing.T) (*net.UnixConn, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	return conn, path
+}
+
+func TestJournalFieldName(t *testing.T) {
+	names := map[string]string{
+		"request_id":            "REQUEST_ID",
+		"__private":             "PRIVATE",
+		"my-field.name":         "MY_FIELD_NAME",
+		"1st":                   "X1ST",
+		"":                      "X",
+		strings.Repeat("a", 70): strings.Repeat("A", 64),
+	}
+	for name, expected := range names {
+		if result := journalFieldName(name); result != expected {
+			t.Errorf("field name of %q is %q, expected %q", name, result, expected)
+		}
+	}
+}
+
+func TestJournalWriter(t *testing.T) {
+	conn, path := listenJournal(t)
+	defer conn.Close()
+
+	w, err := NewJournalWriter(JournalSocket(path), JournalIdentifier("app"), JournalField("service_version", "1.2.3"),
+		JournalRecordField("request_id", func(r *Record) string {
+			if args := r.Args(); len(args) > 0 {
+				return args[0].(string)
+			}
+			return ""
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	l.Errorf("multi\nline")
+	_, _, line, _ := runtime.Caller(0)
+	buf := make([]byte, 4096)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournalEntry(t, buf[:n])
+	expected := map[string]string{
+		"MESSAGE":           "multi\nline",
+		"PRIORITY":          "3",
+		"CODE_LINE":         strconv.Itoa(line - 1),
+		"GOLOG_LEVEL":       "ERROR",
+		"CODE_FUNC":         "github.com/keakon/golog.TestJournalWriter",
+		"SYSLOG_IDENTIFIER": "app",
+		"SERVICE_VERSION":   "1.2.3",
+	}
+	for name, value := range expected {
+		if fields[name] != value {
+			t.Errorf("field %s is %q, expected %q", name, fields[name], value)
+		}
+	}
+	if !strings.HasSuffix(fields["CODE_FILE"], "journald_test.go") {
+		t.Errorf("field CODE_FILE is %q", fields["CODE_FILE"])
+	}
+	if _, ok := fields["REQUEST_ID"]; ok {
+		t.Error("empty field REQUEST_ID is sent")
+	}
+
+	const notice Level = 25
+	registerTestLevel(t, notice, "N", "NOTICE")
+	l.LogAtf(notice, "%s done", "r-1")
+	n, err = conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = parseJournalEntry(t, buf[:n])
+	if fields["PRIORITY"] != "5" || fields["GOLOG_LEVEL"] != "NOTICE" || fields["REQUEST_ID"] != "r-1" {
+		t.Errorf("fields are %q", fields)
+	}
+
+	w.Write([]byte("plain\n"))
+	n, err = conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields = parseJournalEntry(t, buf[:n])
+	if fields["MESSAGE"] != "plain" || fields["PRIORITY"] != "6" || fields["CODE_FILE"] != "" {
+		t.Errorf("fields are %q", fields)
+	}
+
+	l.Close()
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+}
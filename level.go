@@ -0,0 +1,142 @@
+package golog
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Level specifies the log level.
+// The built-in levels are spaced out, so custom levels can be registered between them
+// by RegisterLevel(), e.g. a trace level of 5 or a notice level of 25.
+type Level uint8
+
+// All the built-in log levels.
+const (
+	DebugLevel Level = 10
+	InfoLevel  Level = 20
+	WarnLevel  Level = 30
+	ErrorLevel Level = 40
+	CritLevel  Level = 50
+	PanicLevel Level = 60 // logged by Panic() and Panicf(), which panic after logging
+	FatalLevel Level = 70 // logged by Fatal() and Fatalf(), which exit the process after logging
+
+	disabledLevel Level = ^Level(0)
+)
+
+var (
+	// levelShortNames and levelLongNames are the names of the levels, indexed by level.
+	// They are empty for the unregistered levels.
+	levelShortNames [256]string
+	levelLongNames  [256]string
+	levelLowerNames [256]string // the lowercase long names, used by HTTPWriter
+	levelNameWidth  int         // the length of the longest long name, used by the %P directive
+
+	// levelNamesByLower maps the lowercase long names (and aliases) to the levels.
+	levelNamesByLower = map[string]Level{
+		"warning":  WarnLevel,
+		"critical": CritLevel,
+	}
+)
+
+func init() {
+	for _, lv := range []struct {
+		level     Level
+		shortName string
+		longName  string
+	}{
+		{DebugLevel, "D", "DEBUG"},
+		{InfoLevel, "I", "INFO"},
+		{WarnLevel, "W", "WARN"},
+		{ErrorLevel, "E", "ERROR"},
+		{CritLevel, "C", "CRIT"},
+		{PanicLevel, "P", "PANIC"},
+		{FatalLevel, "F", "FATAL"},
+	} {
+		if err := RegisterLevel(lv.level, lv.shortName, lv.longName); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// RegisterLevel registers a custom level with its short name (rendered by the %l
+// directive, usually a single letter) and long name (e.g. "TRACE"). The long name is
+// case-insensitively parsed as the level by ParseLevel(), and rendered by the %L and %P
+// directives.
+// It can also rename a built-in level.
+// A registered level can be logged by Logger.LogAt() and log.LogAt(), and is handled
+// like the built-in ones by the handlers, filters and writers. A SyslogWriter maps it
+// to the severity of the nearest lower built-in level (or notice between the info and
+// warning levels).
+// It's usually called in an init function, before the levels are parsed from a config.
+func RegisterLevel(lv Level, shortName, longName string) error {
+	if lv == disabledLevel {
+		return errors.New("level 255 is reserved")
+	}
+	if shortName == "" || longName == "" {
+		return errors.New("level names cannot be empty")
+	}
+	lowerName := strings.ToLower(longName)
+	if old, ok := levelNamesByLower[lowerName]; ok && old != lv {
+		return fmt.Errorf("level name %s is used by level %d", longName, old)
+	}
+
+	if old := levelLowerNames[lv]; old != "" {
+		delete(levelNamesByLower, old)
+	}
+	levelShortNames[lv] = shortName
+	levelLongNames[lv] = longName
+	levelLowerNames[lv] = lowerName
+	levelNamesByLower[lowerName] = lv
+	if len(longName) > levelNameWidth {
+		levelNameWidth = len(longName)
+	}
+	return nil
+}
+
+// ParseLevel parses a case-insensitive level name (e.g. "info", "WARNING" or the long
+// name of a custom level), or the number of a level (e.g. "20").
+// A number below DebugLevel is only accepted if it's registered, since the built-in
+// levels used to be numbered from 0 to 4, and parsing an old config as a level below
+// DebugLevel would silently enable all the records.
+func ParseLevel(s string) (Level, error) {
+	s = strings.TrimSpace(s)
+	if lv, ok := levelNamesByLower[strings.ToLower(s)]; ok {
+		return lv, nil
+	}
+	if n, err := strconv.ParseUint(s, 10, 8); err == nil {
+		if lv := Level(n); lv >= DebugLevel || levelLongNames[lv] != "" {
+			return lv, nil
+		}
+		return 0, fmt.Errorf("unregistered level %s is below the debug level (%d)", s, DebugLevel)
+	}
+	return 0, fmt.Errorf("unknown level: %q", s)
+}
+
+// String returns the long name of the level, or "Level(N)" if it's not registered.
+func (lv Level) String() string {
+	if name := levelLongNames[lv]; name != "" {
+		return name
+	}
+	return "Level(" + strconv.Itoa(int(lv)) + ")"
+}
+
+// MarshalText implements encoding.TextMarshaler. It returns the long name of the level,
+// or its number if it's not registered.
+func (lv Level) MarshalText() ([]byte, error) {
+	if name := levelLongNames[lv]; name != "" {
+		return []byte(name), nil
+	}
+	return strconv.AppendUint(nil, uint64(lv), 10), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It parses the text by ParseLevel().
+func (lv *Level) UnmarshalText(text []byte) error {
+	l, err := ParseLevel(string(text))
+	if err != nil {
+		return err
+	}
+	*lv = l
+	return nil
+}
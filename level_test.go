@@ -0,0 +1,157 @@
+package golog
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// registerTestLevel registers a custom level, and unregisters it after the test.
+func registerTestLevel(t *testing.T, lv Level, shortName, longName string) {
+	width := levelNameWidth
+	if err := RegisterLevel(lv, shortName, longName); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		delete(levelNamesByLower, levelLowerNames[lv])
+		levelShortNames[lv] = ""
+		levelLongNames[lv] = ""
+		levelLowerNames[lv] = ""
+		levelNameWidth = width
+	})
+}
+
+func TestRegisterLevel(t *testing.T) {
+	registerTestLevel(t, 5, "T", "TRACE")
+	registerTestLevel(t, 25, "N", "Notice")
+
+	if err := RegisterLevel(6, "T", "trace"); err == nil {
+		t.Error("registered a used name")
+	}
+	if err := RegisterLevel(disabledLevel, "X", "X"); err == nil {
+		t.Error("registered the disabled level")
+	}
+	if err := RegisterLevel(7, "", "X"); err == nil {
+		t.Error("registered an empty name")
+	}
+	if lv, err := ParseLevel("notice"); err != nil || lv != 25 {
+		t.Errorf("parsed notice as %d, %v", lv, err)
+	}
+
+	w := &captureWriter{}
+	h := NewHandler(5, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	l := NewLogger(5)
+	l.AddHandler(h)
+	if err := l.SetVModule("level_test=notice"); err != nil {
+		t.Fatal(err)
+	}
+
+	l.LogAt(5, "trace")
+	l.Info("info")
+	l.LogAtf(25, "notice %d", 1)
+	l.Warn("warn")
+	l.LogAt(26, "unregistered")
+	if s := w.String(); s != "N notice 1\nW warn\n? unregistered\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	// renames a built-in level
+	w.Reset()
+	if err := RegisterLevel(WarnLevel, "w", "Caution"); err != nil {
+		t.Fatal(err)
+	}
+	defer RegisterLevel(WarnLevel, "W", "WARN")
+	if _, err := ParseLevel("warn"); err == nil {
+		t.Error("the old name is still registered")
+	}
+	if err := l.SetVModule(""); err != nil {
+		t.Fatal(err)
+	}
+	l.Warn("warn")
+	if s := w.String(); s != "w warn\n" {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	registerTestLevel(t, 5, "T", "TRACE")
+
+	for s, expected := range map[string]Level{
+		"debug":     DebugLevel,
+		"INFO":      InfoLevel,
+		" Warning ": WarnLevel,
+		"warn":      WarnLevel,
+		"critical":  CritLevel,
+		"fatal":     FatalLevel,
+		"trace":     5,
+		"5":         5,
+		"25":        25,
+	} {
+		if lv, err := ParseLevel(s); err != nil || lv != expected {
+			t.Errorf("parsed %q as %d, %v", s, lv, err)
+		}
+	}
+	for _, s := range []string{"", "verbose", "256", "-1"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+	for _, s := range []string{"0", "1", "2", "3", "4"} { // the numbers of the levels before renumbering
+		if lv, err := ParseLevel(s); err == nil {
+			t.Errorf("parsed legacy level %q as %d", s, lv)
+		}
+	}
+}
+
+func TestLevelText(t *testing.T) {
+	registerTestLevel(t, 5, "T", "TRACE")
+
+	for lv, expected := range map[Level]string{
+		InfoLevel: "INFO",
+		5:         "TRACE",
+		26:        "Level(26)",
+	} {
+		if s := lv.String(); s != expected {
+			t.Errorf("level %d is %q, expected %q", lv, s, expected)
+		}
+	}
+
+	var config struct {
+		Level  Level
+		Levels []Level
+	}
+	if err := json.Unmarshal([]byte(`{"Level":"warning","Levels":["trace","26"]}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if config.Level != WarnLevel || len(config.Levels) != 2 || config.Levels[0] != 5 || config.Levels[1] != 26 {
+		t.Errorf("config is %+v", config)
+	}
+	bs, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(bs); s != `{"Level":"WARN","Levels":["TRACE","26"]}` {
+		t.Errorf("marshaled config is %s", s)
+	}
+	if err := json.Unmarshal([]byte(`{"Level":"verbose"}`), &config); err == nil {
+		t.Error("unmarshaled an unknown level")
+	}
+}
+
+func TestLongLevelFormatPart(t *testing.T) {
+	l, _, w := newCaptureLogger("%L|%P|%m")
+
+	l.Info("info")
+	l.Error("error")
+	l.LogAt(26, "unregistered")
+	if s := w.String(); s != "INFO|INFO |info\nERROR|ERROR|error\nLevel(26)|Level(26)|unregistered\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	registerTestLevel(t, 25, "N", "NOTICE")
+	l.Info("info")
+	if s := w.String(); s != "INFO|INFO  |info\n" {
+		t.Errorf("output is %q", s)
+	}
+}
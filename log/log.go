@@ -1,14 +1,51 @@
 package log
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/keakon/golog"
 )
 
-var defaultLogger *golog.Logger
+var (
+	defaultLogger *golog.Logger
+
+	// osExit is called by Fatal() and Fatalf(). It's a var so tests can mock it.
+	osExit = os.Exit
+)
 
 func nop(args ...interface{})              {}
 func nopf(msg string, args ...interface{}) {}
 
+// panicNop and fatalNop are the Panic and Fatal functions of a disabled level or a nil
+// defaultLogger, which still flush (or close) the defaultLogger and panic (or exit).
+func panicNop(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	if defaultLogger != nil {
+		defaultLogger.Flush()
+	}
+	panic(msg)
+}
+
+func panicNopf(msg string, args ...interface{}) {
+	msg = fmt.Sprintf(msg, args...)
+	if defaultLogger != nil {
+		defaultLogger.Flush()
+	}
+	panic(msg)
+}
+
+func fatalNop(args ...interface{}) {
+	if defaultLogger != nil {
+		defaultLogger.Close()
+	}
+	osExit(1)
+}
+
+func fatalNopf(msg string, args ...interface{}) {
+	fatalNop()
+}
+
 var (
 	// Debug logs a debug level message. It uses fmt.Fprint() to format args.
 	Debug = nop
@@ -20,6 +57,12 @@ var (
 	Error = nop
 	// Crit logs a critical level message. It uses fmt.Fprint() to format args.
 	Crit = nop
+	// Panic logs a panic level message, flushes the defaultLogger, then panics with the
+	// message. It uses fmt.Sprint() to format args.
+	Panic = panicNop
+	// Fatal logs a fatal level message, closes the defaultLogger, then calls os.Exit(1).
+	// It uses fmt.Fprint() to format args.
+	Fatal = fatalNop
 
 	// Debugf logs a debug level message. It uses fmt.Fprintf() to format msg and args.
 	Debugf = nopf
@@ -31,60 +74,111 @@ var (
 	Errorf = nopf
 	// Critf logs a critical level message. It uses fmt.Fprintf() to format msg and args.
 	Critf = nopf
+	// Panicf logs a panic level message, flushes the defaultLogger, then panics with the
+	// message. It uses fmt.Sprintf() to format msg and args.
+	Panicf = panicNopf
+	// Fatalf logs a fatal level message, closes the defaultLogger, then calls os.Exit(1).
+	// It uses fmt.Fprintf() to format msg and args.
+	Fatalf = fatalNopf
+
+	// builtinLevels are the built-in levels, whose indexes are used by the tables below.
+	builtinLevels = [7]golog.Level{
+		golog.DebugLevel, golog.InfoLevel, golog.WarnLevel, golog.ErrorLevel,
+		golog.CritLevel, golog.PanicLevel, golog.FatalLevel,
+	}
+
+	// logVars / logfVars index by the index of the built-in level so SetDefaultLogger and
+	// SetLogFunc / SetLogfFunc can rewrite the matching package-level dispatch variable
+	// directly, without a switch.
+	logVars = [7]*func(args ...interface{}){
+		&Debug, &Info, &Warn, &Error, &Crit, &Panic, &Fatal,
+	}
+	logfVars = [7]*func(msg string, args ...interface{}){
+		&Debugf, &Infof, &Warnf, &Errorf, &Critf, &Panicf, &Fatalf,
+	}
+
+	// customLogFuncs / customLogfFuncs are the functions of the custom levels set by
+	// SetLogFunc / SetLogfFunc, which are called by LogAt / LogAtf.
+	customLogFuncs  = map[golog.Level]func(args ...interface{}){}
+	customLogfFuncs = map[golog.Level]func(msg string, args ...interface{}){}
 
-	// logVars / logfVars index by log level so SetDefaultLogger and SetLogFunc /
-	// SetLogfFunc can rewrite the matching package-level dispatch variable directly,
-	// without a switch.
-	logVars = [5]*func(args ...interface{}){
-		&Debug, &Info, &Warn, &Error, &Crit,
+	// nopFuncs / nopfFuncs are the functions of the disabled levels.
+	nopFuncs = [7]func(args ...interface{}){
+		nop, nop, nop, nop, nop, panicNop, fatalNop,
 	}
-	logfVars = [5]*func(msg string, args ...interface{}){
-		&Debugf, &Infof, &Warnf, &Errorf, &Critf,
+	nopfFuncs = [7]func(msg string, args ...interface{}){
+		nopf, nopf, nopf, nopf, nopf, panicNopf, fatalNopf,
 	}
 
-	logFuncs = [5]func(args ...interface{}){
+	logFuncs = [7]func(args ...interface{}){
 		func(args ...interface{}) {
-			file, line := golog.Caller(1) // deeper caller would be more expensive; do not init these via a loop
-			defaultLogger.Log(golog.DebugLevel, file, line, "", args...)
+			pc := golog.CallerPC(1) // deeper caller would be more expensive; do not init these via a loop
+			defaultLogger.LogPC(golog.DebugLevel, pc, "", args...)
 		},
 		func(args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.InfoLevel, file, line, "", args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.InfoLevel, pc, "", args...)
 		},
 		func(args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.WarnLevel, file, line, "", args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.WarnLevel, pc, "", args...)
 		},
 		func(args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.ErrorLevel, file, line, "", args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.ErrorLevel, pc, "", args...)
 		},
 		func(args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.CritLevel, file, line, "", args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.CritLevel, pc, "", args...)
+		},
+		func(args ...interface{}) {
+			pc := golog.CallerPC(1)
+			msg := fmt.Sprint(args...)
+			defaultLogger.LogPC(golog.PanicLevel, pc, msg)
+			defaultLogger.Flush()
+			panic(msg)
+		},
+		func(args ...interface{}) {
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.FatalLevel, pc, "", args...)
+			defaultLogger.Close()
+			osExit(1)
 		},
 	}
 
-	logfFuncs = [5]func(msg string, args ...interface{}){
+	logfFuncs = [7]func(msg string, args ...interface{}){
 		func(msg string, args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.DebugLevel, file, line, msg, args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.DebugLevel, pc, msg, args...)
 		},
 		func(msg string, args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.InfoLevel, file, line, msg, args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.InfoLevel, pc, msg, args...)
 		},
 		func(msg string, args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.WarnLevel, file, line, msg, args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.WarnLevel, pc, msg, args...)
 		},
 		func(msg string, args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.ErrorLevel, file, line, msg, args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.ErrorLevel, pc, msg, args...)
 		},
 		func(msg string, args ...interface{}) {
-			file, line := golog.Caller(1)
-			defaultLogger.Log(golog.CritLevel, file, line, msg, args...)
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.CritLevel, pc, msg, args...)
+		},
+		func(msg string, args ...interface{}) {
+			pc := golog.CallerPC(1)
+			msg = fmt.Sprintf(msg, args...)
+			defaultLogger.LogPC(golog.PanicLevel, pc, msg)
+			defaultLogger.Flush()
+			panic(msg)
+		},
+		func(msg string, args ...interface{}) {
+			pc := golog.CallerPC(1)
+			defaultLogger.LogPC(golog.FatalLevel, pc, msg, args...)
+			defaultLogger.Close()
+			osExit(1)
 		},
 	}
 
@@ -92,7 +186,7 @@ var (
 	// Caller() stack walk. They are selected by SetDefaultLogger when the logger's
 	// format does not render the source location (no %s/%S), avoiding ~50% of the
 	// per-call cost on those formats.
-	logFuncsNoCaller = [5]func(args ...interface{}){
+	logFuncsNoCaller = [7]func(args ...interface{}){
 		func(args ...interface{}) {
 			defaultLogger.Log(golog.DebugLevel, "", 0, "", args...)
 		},
@@ -108,9 +202,20 @@ var (
 		func(args ...interface{}) {
 			defaultLogger.Log(golog.CritLevel, "", 0, "", args...)
 		},
+		func(args ...interface{}) {
+			msg := fmt.Sprint(args...)
+			defaultLogger.Log(golog.PanicLevel, "", 0, msg)
+			defaultLogger.Flush()
+			panic(msg)
+		},
+		func(args ...interface{}) {
+			defaultLogger.Log(golog.FatalLevel, "", 0, "", args...)
+			defaultLogger.Close()
+			osExit(1)
+		},
 	}
 
-	logfFuncsNoCaller = [5]func(msg string, args ...interface{}){
+	logfFuncsNoCaller = [7]func(msg string, args ...interface{}){
 		func(msg string, args ...interface{}) {
 			defaultLogger.Log(golog.DebugLevel, "", 0, msg, args...)
 		},
@@ -126,22 +231,56 @@ var (
 		func(msg string, args ...interface{}) {
 			defaultLogger.Log(golog.CritLevel, "", 0, msg, args...)
 		},
+		func(msg string, args ...interface{}) {
+			msg = fmt.Sprintf(msg, args...)
+			defaultLogger.Log(golog.PanicLevel, "", 0, msg)
+			defaultLogger.Flush()
+			panic(msg)
+		},
+		func(msg string, args ...interface{}) {
+			defaultLogger.Log(golog.FatalLevel, "", 0, msg, args...)
+			defaultLogger.Close()
+			osExit(1)
+		},
 	}
 )
 
+// builtinIndex returns the index of a built-in level in builtinLevels, or -1 if it's
+// not a built-in level.
+func builtinIndex(level golog.Level) int {
+	for i, lv := range builtinLevels {
+		if lv == level {
+			return i
+		}
+	}
+	return -1
+}
+
 // SetLogFunc sets the log function with the specified level for the defaultLogger.
-// This function should be called before SetDefaultLogger.
+// For a custom level, f is called by LogAt() instead of logging with the defaultLogger
+// (a nil f restores it), so it should skip one more frame than the built-in levels to
+// find the caller, e.g. golog.CallerPC(2).
+// This function should be called after SetDefaultLogger, which resets the functions.
 func SetLogFunc(f func(args ...interface{}), level golog.Level) {
-	if int(level) < len(logVars) {
-		*logVars[level] = f
+	if i := builtinIndex(level); i >= 0 {
+		*logVars[i] = f
+	} else if f != nil {
+		customLogFuncs[level] = f
+	} else {
+		delete(customLogFuncs, level)
 	}
 }
 
 // SetLogfFunc sets the logf function with the specified level for the defaultLogger.
-// This function should be called before SetDefaultLogger.
+// For a custom level, f is called by LogAtf() like SetLogFunc.
+// This function should be called after SetDefaultLogger, which resets the functions.
 func SetLogfFunc(f func(msg string, args ...interface{}), level golog.Level) {
-	if int(level) < len(logfVars) {
-		*logfVars[level] = f
+	if i := builtinIndex(level); i >= 0 {
+		*logfVars[i] = f
+	} else if f != nil {
+		customLogfFuncs[level] = f
+	} else {
+		delete(customLogfFuncs, level)
 	}
 }
 
@@ -150,26 +289,68 @@ func SetLogfFunc(f func(msg string, args ...interface{}), level golog.Level) {
 // This function should be called before using the logging functions below.
 func SetDefaultLogger(l *golog.Logger) {
 	defaultLogger = l
+	for level := range customLogFuncs {
+		delete(customLogFuncs, level)
+	}
+	for level := range customLogfFuncs {
+		delete(customLogfFuncs, level)
+	}
 	if l == nil {
-		for level := golog.DebugLevel; level <= golog.CritLevel; level++ {
-			*logVars[level] = nop
-			*logfVars[level] = nopf
+		for i := range builtinLevels {
+			*logVars[i] = nopFuncs[i]
+			*logfVars[i] = nopfFuncs[i]
 		}
 		return
 	}
 	minLevel := l.GetMinLevel()
 	needsCaller := l.NeedsCaller()
-	for level := golog.DebugLevel; level <= golog.CritLevel; level++ {
+	for i, level := range builtinLevels {
 		if level < minLevel {
-			*logVars[level] = nop
-			*logfVars[level] = nopf
+			*logVars[i] = nopFuncs[i]
+			*logfVars[i] = nopfFuncs[i]
 		} else if needsCaller {
-			*logVars[level] = logFuncs[level]
-			*logfVars[level] = logfFuncs[level]
+			*logVars[i] = logFuncs[i]
+			*logfVars[i] = logfFuncs[i]
 		} else {
-			*logVars[level] = logFuncsNoCaller[level]
-			*logfVars[level] = logfFuncsNoCaller[level]
+			*logVars[i] = logFuncsNoCaller[i]
+			*logfVars[i] = logfFuncsNoCaller[i]
+		}
+	}
+}
+
+// LogAt logs a message of the level with the defaultLogger, which can be a custom
+// level registered by golog.RegisterLevel(). It uses fmt.Fprint() to format args.
+// It neither panics nor exits for the panic and fatal levels.
+// It calls the function set by SetLogFunc() instead for a custom level.
+func LogAt(level golog.Level, args ...interface{}) {
+	if f := customLogFuncs[level]; f != nil {
+		f(args...)
+		return
+	}
+	if l := defaultLogger; l != nil && l.IsEnabledFor(level) {
+		var pc uintptr
+		if l.NeedsCaller() {
+			pc = golog.CallerPC(1)
+		}
+		l.LogPC(level, pc, "", args...)
+	}
+}
+
+// LogAtf logs a message of the level with the defaultLogger, which can be a custom
+// level registered by golog.RegisterLevel(). It uses fmt.Fprintf() to format msg and args.
+// It neither panics nor exits for the panic and fatal levels.
+// It calls the function set by SetLogfFunc() instead for a custom level.
+func LogAtf(level golog.Level, msg string, args ...interface{}) {
+	if f := customLogfFuncs[level]; f != nil {
+		f(msg, args...)
+		return
+	}
+	if l := defaultLogger; l != nil && l.IsEnabledFor(level) {
+		var pc uintptr
+		if l.NeedsCaller() {
+			pc = golog.CallerPC(1)
 		}
+		l.LogPC(level, pc, msg, args...)
 	}
 }
 
@@ -3,7 +3,10 @@ package log
 import (
 	"bytes"
 	"errors"
+	"fmt"
+	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 
@@ -91,7 +94,7 @@ func TestSetLogFunc(t *testing.T) {
 	SetLogFunc(errorFunc, golog.DebugLevel)
 	SetLogfFunc(errorfFunc, golog.DebugLevel)
 
-	for level := golog.DebugLevel; level <= golog.CritLevel; level++ {
+	for _, level := range builtinLevels[:5] { // excludes the panic and fatal levels
 		SetLogFunc(errorFunc, level)
 		SetLogfFunc(errorfFunc, level)
 	}
@@ -309,6 +312,118 @@ func TestLogFuncsNoSource(t *testing.T) {
 	l.Close()
 }
 
+func TestLogFuncsVModule(t *testing.T) {
+	w := &memoryWriter{}
+	h := golog.NewHandler(golog.DebugLevel, golog.ParseFormat("%l %m"))
+	h.AddWriter(w)
+	l := golog.NewLogger(golog.InfoLevel)
+	l.AddHandler(h)
+	if err := l.SetVModule("log_test=debug"); err != nil {
+		t.Fatal(err)
+	}
+	SetDefaultLogger(l)
+
+	Debug("1")
+	Debugf("%d", 2)
+	if s := w.Buffer.String(); s != "D 1\nD 2\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Buffer.Reset()
+	if err := l.SetVModule("other=debug,log_test=error"); err != nil {
+		t.Fatal(err)
+	}
+	SetDefaultLogger(l)
+	Debug("1")
+	Warn("2")
+	Error("3")
+	if s := w.Buffer.String(); s != "E 3\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	SetDefaultLogger(nil)
+	l.Close()
+}
+
+func TestLogFuncsStackTrace(t *testing.T) {
+	w := &memoryWriter{}
+	h := golog.NewHandler(golog.DebugLevel, golog.ParseFormat("%m"))
+	h.AddWriter(w)
+	l := golog.NewLogger(golog.DebugLevel)
+	l.AddHandler(h)
+	l.SetStackTraceLevel(golog.ErrorLevel)
+	SetDefaultLogger(l)
+
+	Error("1")
+	Errorf("%d", 2)
+	s := w.Buffer.String()
+	i := strings.Index(s, "\n2\n")
+	if i < 0 {
+		t.Fatalf("output is %q", s)
+	}
+	for _, record := range []string{s[:i+1], s[i+1:]} {
+		lines := strings.Split(record, "\n")
+		if len(lines) < 3 || lines[1] != "\tgithub.com/keakon/golog/log.TestLogFuncsStackTrace" {
+			t.Errorf("record is %q", record)
+		}
+	}
+
+	SetDefaultLogger(nil)
+	l.Close()
+}
+
+func TestLogAt(t *testing.T) {
+	const noticeLevel = golog.InfoLevel + 5
+	if err := golog.RegisterLevel(noticeLevel, "N", "NOTICE"); err != nil {
+		t.Fatal(err)
+	}
+
+	w := &memoryWriter{}
+	h := golog.NewHandler(golog.DebugLevel, golog.ParseFormat("%l %m %s"))
+	h.AddWriter(w)
+	l := golog.NewLogger(noticeLevel)
+	l.AddHandler(h)
+	SetDefaultLogger(l)
+
+	LogAt(golog.InfoLevel, "info")
+	LogAt(noticeLevel, "notice")
+	LogAtf(noticeLevel, "notice %d", 2)
+	_, _, line, _ := runtime.Caller(0)
+	expected := fmt.Sprintf("N notice log_test:%d\nN notice 2 log_test:%d\n", line-2, line-1)
+	if s := w.Buffer.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Buffer.Reset()
+	SetLogFunc(func(args ...interface{}) {
+		l.LogPC(golog.WarnLevel, golog.CallerPC(2), "", args...)
+	}, noticeLevel)
+	SetLogfFunc(func(msg string, args ...interface{}) {
+		l.LogPC(golog.WarnLevel, golog.CallerPC(2), msg, args...)
+	}, noticeLevel)
+	LogAt(noticeLevel, "custom")
+	LogAtf(noticeLevel, "custom %d", 2)
+	_, _, line, _ = runtime.Caller(0)
+	expected = fmt.Sprintf("W custom log_test:%d\nW custom 2 log_test:%d\n", line-2, line-1)
+	if s := w.Buffer.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+
+	SetLogFunc(nil, noticeLevel)
+	w.Buffer.Reset()
+	LogAt(noticeLevel, "notice")
+	if s := w.Buffer.String(); !strings.HasPrefix(s, "N notice") {
+		t.Errorf("output is %q", s)
+	}
+
+	SetDefaultLogger(nil)
+	if len(customLogfFuncs) != 0 {
+		t.Error("custom log functions are not reset")
+	}
+	LogAt(noticeLevel, "notice")
+	l.Close()
+}
+
 func TestIsEnabledFor(t *testing.T) {
 	SetDefaultLogger(nil)
 	if IsEnabledFor(golog.DebugLevel) {
@@ -354,6 +469,91 @@ func TestSetDefaultLoggerNilDisablesPackageLoggers(t *testing.T) {
 	l.Close()
 }
 
+// flushWriter records the written bytes, and counts its flushes and closes.
+type flushWriter struct {
+	bytes.Buffer
+	flushes int
+	closes  int
+}
+
+func (w *flushWriter) Flush() error {
+	w.flushes++
+	return nil
+}
+
+func (w *flushWriter) Close() error {
+	w.closes++
+	return nil
+}
+
+func TestPanicFatalFuncs(t *testing.T) {
+	code := -1
+	osExit = func(c int) { code = c }
+	defer func() { osExit = os.Exit }()
+
+	for _, format := range []string{"%l %m", "%l %m %s"} { // without and with the caller
+		w := &flushWriter{}
+		h := golog.NewHandler(golog.DebugLevel, golog.ParseFormat(format))
+		h.AddWriter(w)
+		l := golog.NewLogger(golog.DebugLevel)
+		l.AddHandler(h)
+		SetDefaultLogger(l)
+
+		for _, logPanic := range []func(){
+			func() { Panic("panic") },
+			func() { Panicf("panic%s", "f") },
+		} {
+			func() {
+				defer func() {
+					if r := recover(); r == nil {
+						t.Error("didn't panic")
+					}
+				}()
+				logPanic()
+			}()
+		}
+		if !strings.HasPrefix(w.String(), "P panic") || !strings.Contains(w.String(), "\nP panicf") || w.flushes != 2 {
+			t.Errorf("output is %q after %d flushes", w.String(), w.flushes)
+		}
+
+		Fatalf("fatal%s", "f")
+		if code != 1 || w.closes != 1 || !strings.Contains(w.String(), "\nF fatalf") {
+			t.Errorf("output is %q after %d closes, exit code is %d", w.String(), w.closes, code)
+		}
+		code = -1
+		Fatal("fatal") // the logger was closed, and it still exits
+		if code != 1 {
+			t.Errorf("exit code is %d", code)
+		}
+	}
+
+	// a disabled level or a nil defaultLogger still panics and exits
+	w := &flushWriter{}
+	h := golog.NewHandler(golog.DebugLevel, golog.DefaultFormatter)
+	h.AddWriter(w)
+	l := golog.NewLogger(^golog.Level(0))
+	l.AddHandler(h)
+	for _, logger := range []*golog.Logger{l, nil} {
+		SetDefaultLogger(logger)
+		func() {
+			defer func() {
+				if r := recover(); r != "panic 1" {
+					t.Errorf("recovered %v", r)
+				}
+			}()
+			Panicf("panic %d", 1)
+		}()
+		code = -1
+		Fatal("fatal")
+		if code != 1 {
+			t.Errorf("exit code is %d", code)
+		}
+	}
+	if w.Len() != 0 || w.flushes != 1 || w.closes != 1 {
+		t.Errorf("output is %q after %d flushes and %d closes", w.String(), w.flushes, w.closes)
+	}
+}
+
 func BenchmarkDiscardLogger(b *testing.B) {
 	golog.StartFastTimer()
 	defer golog.StopFastTimer()
@@ -215,13 +215,56 @@ func TestAddHandler(t *testing.T) {
 		t.Errorf("the logger has %d handlers", count)
 	}
 
-	for i := 0; i < count-1; i++ {
-		if l.handlers[i].level > l.handlers[i+1].level {
-			t.Errorf("handlers[%d].level > handlers[%d].level", i, i+1)
+	for i, h := range []*Handler{ch, eh, wh, ih, dh} { // kept in the order of adding
+		if l.handlers[i] != h {
+			t.Errorf("handlers[%d] is of level %d", i, l.handlers[i].level)
 		}
 	}
 }
 
+func TestLevelRangeHandler(t *testing.T) {
+	out := &captureWriter{}
+	outHandler := NewLevelRangeHandler(DebugLevel, InfoLevel, ParseFormat("%l %m"))
+	outHandler.AddWriter(out)
+	errOut := &captureWriter{}
+	errHandler := NewHandler(WarnLevel, ParseFormat("%l %m"))
+	errHandler.AddWriter(errOut)
+	all := &captureWriter{}
+	allHandler := NewLevelRangeHandler(DebugLevel, CritLevel, ParseFormat("%l %m"))
+	allHandler.AddWriter(all)
+
+	// the order of adding doesn't matter
+	l := NewLogger(DebugLevel)
+	l.AddHandler(errHandler)
+	l.AddHandler(outHandler)
+	l.AddHandler(allHandler)
+	if !l.IsEnabledFor(DebugLevel) {
+		t.Error("the logger is not enabled for debug level")
+	}
+
+	l.Debug("d")
+	l.Info("i")
+	l.Warn("w")
+	l.Crit("c")
+	if s := out.String(); s != "D d\nI i\n" {
+		t.Errorf("out is %q", s)
+	}
+	if s := errOut.String(); s != "W w\nC c\n" {
+		t.Errorf("errOut is %q", s)
+	}
+	if s := all.String(); s != "D d\nI i\nW w\nC c\n" {
+		t.Errorf("all is %q", s)
+	}
+
+	r := &Record{level: WarnLevel}
+	if outHandler.Handle(r) {
+		t.Error("handled a record above its max level")
+	}
+	if !errHandler.Handle(r) || !allHandler.Handle(r) {
+		t.Error("didn't handle a record in its level range")
+	}
+}
+
 func TestCloseLogger(t *testing.T) {
 	l := &Logger{}
 	l.Close()
@@ -243,8 +286,83 @@ func TestCloseLogger(t *testing.T) {
 	l.Close()
 }
 
+func newFlushTestLogger(t *testing.T, level Level) (*Logger, string) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewBufferedFileWriter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	l := NewLogger(level)
+	l.AddHandler(h)
+	return l, path
+}
+
+func readFile(t *testing.T, path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestPanic(t *testing.T) {
+	l, path := newFlushTestLogger(t, DebugLevel)
+	defer l.Close()
+
+	for i, logPanic := range []func(){
+		func() { l.Panic("panic ", 1) },
+		func() { l.Panicf("panic %d%%", 2) },
+	} {
+		func() {
+			defer func() {
+				expected := []string{"panic 1", "panic 2%"}[i]
+				if r := recover(); r != expected {
+					t.Errorf("recovered %v, expected %q", r, expected)
+				}
+			}()
+			logPanic()
+		}()
+	}
+	if s := readFile(t, path); s != "P panic 1\nP panic 2%\n" {
+		t.Errorf("flushed %q", s)
+	}
+}
+
+func TestFatal(t *testing.T) {
+	code := -1
+	osExit = func(c int) { code = c }
+	defer func() { osExit = os.Exit }()
+
+	l, path := newFlushTestLogger(t, DebugLevel)
+	l.Info("info")
+	l.Fatalf("fatal %d", 1)
+	if code != 1 {
+		t.Errorf("exit code is %d", code)
+	}
+	if len(l.handlers) > 0 {
+		t.Error("fatal didn't close the handlers")
+	}
+	if s := readFile(t, path); s != "I info\nF fatal 1\n" {
+		t.Errorf("flushed %q", s)
+	}
+
+	// the buffered records are flushed and it exits even if the fatal level is disabled
+	code = -1
+	l, path = newFlushTestLogger(t, disabledLevel)
+	l.Log(InfoLevel, "", 0, "info")
+	l.Fatal("fatal")
+	if code != 1 {
+		t.Errorf("exit code is %d", code)
+	}
+	if s := readFile(t, path); s != "I info\n" {
+		t.Errorf("flushed %q", s)
+	}
+}
+
 func TestNeedsCaller(t *testing.T) {
-	// Formatter level: only %s and %S require the caller.
+	// Formatter level: only the source and function directives require the caller.
 	if !DefaultFormatter.NeedsCaller() {
 		t.Error("DefaultFormatter should need the caller")
 	}
@@ -254,6 +372,11 @@ func TestNeedsCaller(t *testing.T) {
 	if ParseFormat("[%l %D %T] %m").NeedsCaller() {
 		t.Error("a format without a source directive should not need the caller")
 	}
+	for _, format := range []string{"%f %m", "%F %m", "%p %m"} {
+		if !ParseFormat(format).NeedsCaller() {
+			t.Errorf("format %q should need the caller", format)
+		}
+	}
 
 	// Logger level: needsCaller is the OR over its handlers' formatters.
 	noSource := NewLogger(InfoLevel)
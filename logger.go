@@ -1,28 +1,17 @@
 package golog
 
 import (
+	"bytes"
+	"fmt"
 	"io"
-	"sort"
+	"os"
 	"sync"
 	"time"
 )
 
-// Level specifies the log level.
-type Level uint8
-
-// All the log levels.
-const (
-	DebugLevel Level = iota
-	InfoLevel
-	WarnLevel
-	ErrorLevel
-	CritLevel
-
-	disabledLevel Level = ^Level(0)
-)
-
 var (
-	levelNames = []byte("DIWEC")
+	// osExit is called by Fatal() and Fatalf(). It's a var so tests can mock it.
+	osExit = os.Exit
 
 	internalLogger *Logger
 
@@ -38,8 +27,7 @@ var (
 // recursive logging loops.
 func logError(err error) {
 	if internalLogger != nil {
-		file, line := Caller(1)
-		internalLogger.Log(ErrorLevel, file, line, err.Error())
+		internalLogger.LogPC(ErrorLevel, CallerPC(1), err.Error())
 	}
 }
 
@@ -49,19 +37,101 @@ type Record struct {
 	time    string
 	file    string
 	message string
+	stack   string // the stack trace, see Logger.SetStackTraceLevel()
 	args    []interface{}
 	tm      time.Time
+	pc      uintptr // the program counter of the caller, 0 if unknown
 	line    int
-	level   Level
+	// levelStart and levelEnd are the range of the level name in the bytes formatted by
+	// the last Formatter.Format() call. The levelEnd is 0 if it's not rendered.
+	levelStart int
+	levelEnd   int
+	level      Level
+}
+
+// stamp sets the time of the record, from the fast timer if it's started.
+func (r *Record) stamp() {
+	if snap := fastTimer.load(); snap != nil {
+		r.date = snap.date
+		r.time = snap.time
+	} else {
+		r.date = ""
+		r.time = ""
+		r.tm = now()
+	}
+}
+
+// Level returns the level of the record.
+func (r *Record) Level() Level {
+	return r.level
+}
+
+// File returns the source file path of the record.
+// It's empty if no handler, writer or filter needs the caller.
+func (r *Record) File() string {
+	return r.file
+}
+
+// Line returns the source line of the record.
+// It's 0 if no handler, writer or filter needs the caller.
+func (r *Record) Line() int {
+	return r.line
+}
+
+// Message returns the message of the record formatted with its args.
+// It formats the message each time it's called if there are args.
+func (r *Record) Message() string {
+	if len(r.args) == 0 {
+		return r.message
+	}
+	buf := bufPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	writeMessage(r, buf)
+	msg := buf.String()
+	if buf.Cap() <= maxPooledBufSize {
+		bufPool.Put(buf)
+	}
+	return msg
+}
+
+// Args returns the args of the record, which should not be modified.
+func (r *Record) Args() []interface{} {
+	return r.args
+}
+
+// Time returns the time of the record.
+// It's truncated to seconds if the fast timer is started.
+func (r *Record) Time() time.Time {
+	if r.time == "" {
+		return r.tm
+	}
+	tm, _ := time.ParseInLocation("2006-01-02 15:04:05", r.date+" "+r.time, time.Local)
+	return tm
+}
+
+// Function returns the fully qualified name of the function logging the record, e.g.
+// "github.com/keakon/golog.(*Handler).Handle".
+// It's empty if the caller is not needed or unknown, or the record is logged by
+// Logger.Log() instead of Logger.LogPC().
+func (r *Record) Function() string {
+	if r.pc == 0 {
+		return ""
+	}
+	return callerFrame(r.pc).Function
 }
 
 // A Logger is a leveled logger with several handlers.
 type Logger struct {
 	handlers    []*Handler
+	filters     []Filter
 	minLevel    Level // the min level of the logger and its handlers
 	level       Level // the lowest acceptable level of the logger
 	isInternal  bool
-	needsCaller bool // whether any handler's formatter renders the source (%s/%S)
+	needsCaller bool     // whether any handler renders the source (%s/%S), its writers or filters need it, or vmodule is set
+	vmodule     *vmodule // the levels of the source files overriding its level
+
+	stackLevel   Level // the lowest level to capture the stack trace if captureStack
+	captureStack bool
 }
 
 // NewLogger creates a new Logger of the given level.
@@ -74,26 +144,72 @@ func NewLogger(lv Level) *Logger {
 func (l *Logger) AddHandler(h *Handler) {
 	h.isInternal = l.isInternal
 	l.handlers = append(l.handlers, h)
+	l.update()
+}
 
-	// Track whether any handler actually renders the source location, so the
-	// logging methods can skip Caller() when it would be discarded. A nil
-	// formatter is treated conservatively as needing the caller.
-	if h.formatter == nil || h.formatter.needsCaller {
-		l.needsCaller = true
-	}
+// AddFilter adds a Filter to the Logger, which is checked before all its handlers.
+func (l *Logger) AddFilter(f Filter) {
+	l.filters = append(l.filters, f)
+	l.update()
+}
 
-	if len(l.handlers) > 1 {
-		sort.Slice(l.handlers, func(i, j int) bool {
-			return l.handlers[i].level < l.handlers[j].level
-		})
+// SetVModule sets the levels of the source files matching the patterns, overriding
+// the level of the logger (but not the levels of its handlers). The spec is a comma
+// separated list of pattern=level, e.g. "writer*=debug,net/*=warn".
+// A pattern without a slash is matched against the base name of the source file,
+// otherwise against its last path components, both without the ".go" extension.
+// The first matched pattern is used. An empty spec removes all the patterns.
+//
+// The source file is needed to check the patterns, so the logging methods compute the
+// caller once it's set. The levels lower than the level of the logger and all the
+// patterns are still disabled without it.
+// It should be set before calling log.SetDefaultLogger(), which disables the log
+// functions of the levels lower than the minimum level of the logger.
+func (l *Logger) SetVModule(spec string) error {
+	v, err := parseVModule(spec)
+	if err != nil {
+		return err
 	}
+	l.vmodule = v
+	l.update()
+	return nil
+}
 
-	minLevel := l.handlers[0].level
-	if l.level >= minLevel {
-		l.minLevel = l.level
+// update recomputes its minLevel and needsCaller after changing its handlers, filters
+// or vmodule.
+func (l *Logger) update() {
+	if len(l.handlers) == 0 {
+		l.minLevel = disabledLevel // disable all levels for empty logger
 	} else {
+		minLevel := l.level
+		if l.vmodule != nil && l.vmodule.minLevel < minLevel {
+			minLevel = l.vmodule.minLevel
+		}
+		handlerLevel := disabledLevel
+		for _, h := range l.handlers {
+			if h.level < handlerLevel {
+				handlerLevel = h.level
+			}
+		}
+		if handlerLevel > minLevel {
+			minLevel = handlerLevel
+		}
 		l.minLevel = minLevel
 	}
+
+	// Track whether any handler actually uses the source location, so the
+	// logging methods can skip Caller() when it would be discarded.
+	l.needsCaller = l.vmodule != nil
+	for _, h := range l.handlers {
+		if h.NeedsCaller() {
+			l.needsCaller = true
+		}
+	}
+	for _, f := range l.filters {
+		if filterNeedsCaller(f) {
+			l.needsCaller = true
+		}
+	}
 }
 
 // IsEnabledFor returns whether it's enabled for the level.
@@ -107,38 +223,57 @@ func (l *Logger) GetMinLevel() Level {
 	return l.minLevel
 }
 
-// NeedsCaller reports whether any of the logger's handlers renders the source
-// file and line (i.e. uses a %s or %S directive). When it returns false the
-// logging methods skip the Caller() stack walk.
+// NeedsCaller reports whether any of the logger's handlers needs the source
+// file and line (i.e. uses a %s or %S directive, or has a writer needing them),
+// or the logger needs it for its filters or vmodule.
+// When it returns false the logging methods skip the Caller() stack walk.
 func (l *Logger) NeedsCaller() bool {
 	return l.needsCaller
 }
 
 // Log logs a message with context.
 // A logger should check the message level before call its Log().
+// If its vmodule is set, the level of the source file is checked by Log().
 // The line param should be uint32.
 // It's not thread-safe, concurrent messages may be written in a random order
 // through different handlers or writers.
 // But two messages won't be mixed in a single line.
 func (l *Logger) Log(lv Level, file string, line int, msg string, args ...interface{}) {
+	l.log(lv, 0, file, line, msg, args)
+}
+
+// LogPC logs a message like Log(), with the program counter of its caller returned by
+// CallerPC(). Its source and function are resolved from the pc, which is 0 if unknown.
+func (l *Logger) LogPC(lv Level, pc uintptr, msg string, args ...interface{}) {
+	var file string
+	var line int
+	if pc != 0 {
+		frame := callerFrame(pc)
+		file, line = frame.File, frame.Line
+	}
+	l.log(lv, pc, file, line, msg, args)
+}
+
+func (l *Logger) log(lv Level, pc uintptr, file string, line int, msg string, args []interface{}) {
+	if l.vmodule != nil && (lv < l.level || lv < l.vmodule.maxLevel) && lv < l.vmodule.level(file, l.level) {
+		return
+	}
+
 	r := recordPool.Get().(*Record)
 	r.level = lv
-	if snap := fastTimer.load(); snap != nil {
-		r.date = snap.date
-		r.time = snap.time
-	} else {
-		r.date = ""
-		r.time = ""
-		r.tm = now()
-	}
+	r.stamp()
 	r.file = file
 	r.line = line
+	r.pc = pc
 	r.message = msg
 	r.args = args
+	if l.captureStack && lv >= l.stackLevel {
+		r.stack = callerStack()
+	}
 
-	for _, h := range l.handlers {
-		if !h.Handle(r) {
-			break
+	if len(l.filters) == 0 || filterRecord(l.filters, r) {
+		for _, h := range l.handlers { // each handler checks its own level range
+			h.Handle(r)
 		}
 	}
 
@@ -146,6 +281,8 @@ func (l *Logger) Log(lv Level, file string, line int, msg string, args ...interf
 	// record does not pin the previous message, file name or args in memory.
 	r.message = ""
 	r.file = ""
+	r.pc = 0
+	r.stack = ""
 	r.args = nil
 	recordPool.Put(r)
 }
@@ -159,124 +296,206 @@ func (l *Logger) Close() {
 	l.handlers = nil
 }
 
+// Flush flushes the buffered writers of its handlers, without closing them.
+// It's called by Panic() and Panicf() before panicking.
+func (l *Logger) Flush() {
+	for _, h := range l.handlers {
+		h.Flush()
+	}
+}
+
 // Debug logs a debug level message. It uses fmt.Fprint() to format args.
 func (l *Logger) Debug(args ...interface{}) {
 	if l.IsEnabledFor(DebugLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1) // deeper caller will be more expensive
+			pc = CallerPC(1) // deeper caller will be more expensive
 		}
-		l.Log(DebugLevel, file, line, "", args...)
+		l.LogPC(DebugLevel, pc, "", args...)
 	}
 }
 
 // Debugf logs a debug level message. It uses fmt.Fprintf() to format msg and args.
 func (l *Logger) Debugf(msg string, args ...interface{}) {
 	if l.IsEnabledFor(DebugLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(DebugLevel, file, line, msg, args...)
+		l.LogPC(DebugLevel, pc, msg, args...)
 	}
 }
 
 // Info logs a info level message. It uses fmt.Fprint() to format args.
 func (l *Logger) Info(args ...interface{}) {
 	if l.IsEnabledFor(InfoLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(InfoLevel, file, line, "", args...)
+		l.LogPC(InfoLevel, pc, "", args...)
 	}
 }
 
 // Infof logs a info level message. It uses fmt.Fprintf() to format msg and args.
 func (l *Logger) Infof(msg string, args ...interface{}) {
 	if l.IsEnabledFor(InfoLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(InfoLevel, file, line, msg, args...)
+		l.LogPC(InfoLevel, pc, msg, args...)
 	}
 }
 
 // Warn logs a warning level message. It uses fmt.Fprint() to format args.
 func (l *Logger) Warn(args ...interface{}) {
 	if l.IsEnabledFor(WarnLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(WarnLevel, file, line, "", args...)
+		l.LogPC(WarnLevel, pc, "", args...)
 	}
 }
 
 // Warnf logs a warning level message. It uses fmt.Fprintf() to format msg and args.
 func (l *Logger) Warnf(msg string, args ...interface{}) {
 	if l.IsEnabledFor(WarnLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(WarnLevel, file, line, msg, args...)
+		l.LogPC(WarnLevel, pc, msg, args...)
 	}
 }
 
 // Error logs an error level message. It uses fmt.Fprint() to format args.
 func (l *Logger) Error(args ...interface{}) {
 	if l.IsEnabledFor(ErrorLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(ErrorLevel, file, line, "", args...)
+		l.LogPC(ErrorLevel, pc, "", args...)
 	}
 }
 
 // Errorf logs a error level message. It uses fmt.Fprintf() to format msg and args.
 func (l *Logger) Errorf(msg string, args ...interface{}) {
 	if l.IsEnabledFor(ErrorLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(ErrorLevel, file, line, msg, args...)
+		l.LogPC(ErrorLevel, pc, msg, args...)
 	}
 }
 
 // Crit logs a critical level message. It uses fmt.Fprint() to format args.
 func (l *Logger) Crit(args ...interface{}) {
 	if l.IsEnabledFor(CritLevel) {
-		var file string
-		var line int
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(CritLevel, file, line, "", args...)
+		l.LogPC(CritLevel, pc, "", args...)
 	}
 }
 
 // Critf logs a critical level message. It uses fmt.Fprintf() to format msg and args.
 func (l *Logger) Critf(msg string, args ...interface{}) {
 	if l.IsEnabledFor(CritLevel) {
-		var file string
-		var line int
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(CritLevel, pc, msg, args...)
+	}
+}
+
+// LogAt logs a message of the level, which can be a custom level registered by
+// RegisterLevel(). It uses fmt.Fprint() to format args.
+// It neither panics nor exits for the panic and fatal levels.
+func (l *Logger) LogAt(lv Level, args ...interface{}) {
+	if l.IsEnabledFor(lv) {
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(lv, pc, "", args...)
+	}
+}
+
+// LogAtf logs a message of the level, which can be a custom level registered by
+// RegisterLevel(). It uses fmt.Fprintf() to format msg and args.
+// It neither panics nor exits for the panic and fatal levels.
+func (l *Logger) LogAtf(lv Level, msg string, args ...interface{}) {
+	if l.IsEnabledFor(lv) {
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(lv, pc, msg, args...)
+	}
+}
+
+// Panic logs a panic level message, flushes its handlers, then panics with the message.
+// It uses fmt.Sprint() to format args.
+func (l *Logger) Panic(args ...interface{}) {
+	msg := fmt.Sprint(args...)
+	if l.IsEnabledFor(PanicLevel) {
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(PanicLevel, pc, msg)
+	}
+	l.Flush()
+	panic(msg)
+}
+
+// Panicf logs a panic level message, flushes its handlers, then panics with the message.
+// It uses fmt.Sprintf() to format msg and args.
+func (l *Logger) Panicf(msg string, args ...interface{}) {
+	msg = fmt.Sprintf(msg, args...)
+	if l.IsEnabledFor(PanicLevel) {
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(PanicLevel, pc, msg)
+	}
+	l.Flush()
+	panic(msg)
+}
+
+// Fatal logs a fatal level message, closes its handlers, then calls os.Exit(1).
+// It uses fmt.Fprint() to format args.
+func (l *Logger) Fatal(args ...interface{}) {
+	if l.IsEnabledFor(FatalLevel) {
+		var pc uintptr
+		if l.needsCaller {
+			pc = CallerPC(1)
+		}
+		l.LogPC(FatalLevel, pc, "", args...)
+	}
+	l.Close()
+	osExit(1)
+}
+
+// Fatalf logs a fatal level message, closes its handlers, then calls os.Exit(1).
+// It uses fmt.Fprintf() to format msg and args.
+func (l *Logger) Fatalf(msg string, args ...interface{}) {
+	if l.IsEnabledFor(FatalLevel) {
+		var pc uintptr
 		if l.needsCaller {
-			file, line = Caller(1)
+			pc = CallerPC(1)
 		}
-		l.Log(CritLevel, file, line, msg, args...)
+		l.LogPC(FatalLevel, pc, msg, args...)
 	}
+	l.Close()
+	osExit(1)
 }
 
 // NewLoggerWithWriter creates an info level logger with a writer.
@@ -0,0 +1,67 @@
+package golog
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+)
+
+var (
+	// processID, hostname and executable are computed once, and rendered as literals by
+	// the %i, %h and %e directives.
+	processID  = strconv.Itoa(os.Getpid())
+	hostname   = getHostname()
+	executable = filepath.Base(os.Args[0])
+
+	// serviceName and serviceVersion are rendered by the %n and %v directives.
+	serviceName    string
+	serviceVersion string
+)
+
+func getHostname() string {
+	name, err := os.Hostname()
+	if err != nil || name == "" {
+		return string(unknownFile)
+	}
+	return name
+}
+
+// SetService sets the service name and version rendered by the %n and %v directives.
+func SetService(name, version string) {
+	serviceName = name
+	serviceVersion = version
+}
+
+// GoroutineFormatPart is a FormatPart of the goroutine ID placeholder.
+type GoroutineFormatPart struct{}
+
+// Format writes the ID of the current goroutine to the buf.
+// The handlers format the records in the goroutines calling the logging methods, so
+// it's the goroutine logging the record (except the summary records of the handlers).
+func (p *GoroutineFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	var stack [64]byte
+	n := runtime.Stack(stack[:], false) // begins with "goroutine 123 ["
+	id := bytes.TrimPrefix(stack[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(id, ' '); i > 0 {
+		buf.Write(id[:i])
+	} else {
+		buf.Write(unknownFile)
+	}
+}
+
+// ServiceFormatPart is a FormatPart of the service name or version placeholder.
+type ServiceFormatPart struct {
+	version bool
+}
+
+// Format writes the service name (or version if its version is true) set by SetService()
+// to the buf.
+func (p *ServiceFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	if p.version {
+		buf.WriteString(serviceVersion)
+	} else {
+		buf.WriteString(serviceName)
+	}
+}
@@ -0,0 +1,61 @@
+package golog
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMetadataFormatPart(t *testing.T) {
+	SetService("api", "1.2.3")
+	defer SetService("", "")
+
+	f := ParseFormat("[%i|%h|%e|%n|%v] %m")
+	if len(f.formatParts) != 7 { // "[%i|%h|%e|" is merged into a literal
+		t.Errorf("format has %d parts", len(f.formatParts))
+	}
+
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, f)
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	l.Info("test")
+
+	name, _ := os.Hostname()
+	expected := "[" + strconv.Itoa(os.Getpid()) + "|" + name + "|" + filepath.Base(os.Args[0]) + "|api|1.2.3] test\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q, expected %q", s, expected)
+	}
+}
+
+func TestGoroutineFormatPart(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%g"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.Info("")
+		}()
+	}
+	wg.Wait()
+
+	ids := strings.Fields(w.String())
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("goroutine IDs are %v", ids)
+	}
+	for _, id := range ids {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			t.Errorf("goroutine ID %q is not a number", id)
+		}
+	}
+}
@@ -0,0 +1,444 @@
+package golog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	defaultNetMemoryLimit = 1024 * 1024 * 4
+	defaultNetMinBackoff  = time.Millisecond * 100
+	defaultNetMaxBackoff  = time.Second * 10
+
+	netTimeout       = time.Second * 5 // the timeout of dialing and writing
+	netSpillFlag     = os.O_RDWR | os.O_CREATE
+	netSpillReadSize = 64 * 1024
+	lengthPrefixSize = 4
+)
+
+// Framing specifies how a NetWriter separates the records in its stream.
+type Framing uint8
+
+const (
+	// FramingNewline terminates each record with a newline.
+	FramingNewline Framing = iota
+	// FramingLengthPrefix prefixes each record with its length as a 4-byte big-endian
+	// integer. The trailing newline added by the formatter is not sent.
+	FramingLengthPrefix
+)
+
+// A NetWriter is a writer which sends the records to a TCP or Unix socket peer.
+//
+// Write never blocks on the network: the framed records are buffered in memory and
+// sent by a background goroutine. When the peer goes away, the goroutine reconnects
+// with an exponential backoff, while the records are kept in memory up to its memory
+// limit, then appended to its spill file (if set) up to the spill limit, then dropped.
+// The records are sent in order, and the ones left in the spill file when closing are
+// sent by the next NetWriter using the same spill file.
+type NetWriter struct {
+	lock        sync.Mutex
+	network     string
+	addr        string
+	framing     Framing
+	pending     *bytes.Buffer // the records to send, guarded by lock
+	sending     *bytes.Buffer // the records being sent or to be resent, only used by schedule()
+	memoryLimit int
+	spillPath   string
+	spillLimit  int64
+	spill       *os.File
+	spillSize   int64 // guarded by lock
+	spillOffset int64 // the bytes of the spill file already sent, guarded by lock
+	dropped     uint64
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+	conn        net.Conn // only used by schedule()
+	outage      bool     // only used by schedule()
+	updateChan  chan struct{}
+	stopChan    chan struct{}
+	stoppedChan chan struct{}
+	closeOnce   sync.Once
+	closeErr    error
+	closed      bool
+}
+
+// NetWriterOption is an option of NetWriter.
+type NetWriterOption func(*NetWriter)
+
+// NetFraming sets how the records are separated. It defaults to FramingNewline.
+func NetFraming(framing Framing) NetWriterOption {
+	return func(w *NetWriter) {
+		w.framing = framing
+	}
+}
+
+// NetMemoryLimit sets how many bytes can be buffered in memory while the records
+// cannot be sent. It defaults to 4 MB.
+func NetMemoryLimit(size int) NetWriterOption {
+	return func(w *NetWriter) {
+		if size >= 1024 {
+			w.memoryLimit = size
+		}
+	}
+}
+
+// NetSpillFile sets a file to spill the records to when the memory limit is reached,
+// keeping at most maxSize bytes in it.
+func NetSpillFile(path string, maxSize int64) NetWriterOption {
+	return func(w *NetWriter) {
+		w.spillPath = path
+		w.spillLimit = maxSize
+	}
+}
+
+// NetBackoff sets the min and max delays between reconnections.
+// They default to 0.1 and 10 seconds.
+func NetBackoff(min, max time.Duration) NetWriterOption {
+	return func(w *NetWriter) {
+		if min > 0 && max >= min {
+			w.minBackoff = min
+			w.maxBackoff = max
+		}
+	}
+}
+
+// NewNetWriter creates a new NetWriter sending to addr through the network, which
+// should be "tcp", "tcp4", "tcp6" or "unix".
+// The peer doesn't need to be reachable yet, since the writer keeps reconnecting.
+func NewNetWriter(network, addr string, options ...NetWriterOption) (*NetWriter, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+	default:
+		return nil, errors.New("invalid network")
+	}
+
+	w := &NetWriter{
+		network:     network,
+		addr:        addr,
+		pending:     &bytes.Buffer{},
+		sending:     &bytes.Buffer{},
+		memoryLimit: defaultNetMemoryLimit,
+		minBackoff:  defaultNetMinBackoff,
+		maxBackoff:  defaultNetMaxBackoff,
+		updateChan:  make(chan struct{}, 1),
+		stopChan:    make(chan struct{}),
+		stoppedChan: make(chan struct{}),
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	if w.spillPath != "" {
+		f, err := os.OpenFile(w.spillPath, netSpillFlag, fileMode)
+		if err != nil {
+			return nil, err
+		}
+		stat, err := f.Stat()
+		if err != nil {
+			if e := f.Close(); e != nil {
+				logError(e)
+			}
+			return nil, err
+		}
+		w.spill = f
+		w.spillSize = stat.Size() // left by the previous writer
+	}
+
+	go w.schedule()
+	w.notify()
+	return w, nil
+}
+
+// Write frames a byte slice into the buffer.
+func (w *NetWriter) Write(p []byte) (n int, err error) {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return 0, os.ErrClosed
+	}
+
+	mark := w.pending.Len()
+	w.appendFrame(p)
+	if w.pending.Len() > w.memoryLimit {
+		if w.spill == nil || w.spillSize+int64(w.pending.Len()) > w.spillLimit {
+			w.pending.Truncate(mark)
+			w.dropped += uint64(len(p))
+		} else if err = w.spillPending(); err != nil {
+			w.pending.Truncate(mark)
+			w.dropped += uint64(len(p))
+		}
+	}
+	w.lock.Unlock()
+
+	if err != nil {
+		return 0, err
+	}
+	w.notify()
+	return len(p), nil
+}
+
+// appendFrame appends a framed record to the pending buffer.
+func (w *NetWriter) appendFrame(p []byte) {
+	if w.framing == FramingLengthPrefix {
+		p = bytes.TrimSuffix(p, []byte{'\n'})
+		var prefix [lengthPrefixSize]byte
+		binary.BigEndian.PutUint32(prefix[:], uint32(len(p)))
+		w.pending.Write(prefix[:])
+		w.pending.Write(p)
+	} else {
+		w.pending.Write(p)
+		if len(p) == 0 || p[len(p)-1] != '\n' {
+			w.pending.WriteByte('\n')
+		}
+	}
+}
+
+// spillPending moves the pending buffer to the end of the spill file.
+// It should be called within a lock block.
+func (w *NetWriter) spillPending() error {
+	n, err := w.spill.WriteAt(w.pending.Bytes(), w.spillSize)
+	if err != nil {
+		if n > 0 {
+			if e := w.spill.Truncate(w.spillSize); e != nil { // drops the partial frames
+				logError(e)
+			}
+		}
+		return err
+	}
+	w.spillSize += int64(n)
+	w.pending.Reset()
+	return nil
+}
+
+// notify wakes up schedule() to send the buffered records.
+func (w *NetWriter) notify() {
+	select { // ignores if blocked
+	case w.updateChan <- struct{}{}:
+	default:
+	}
+}
+
+// schedule runs in its own goroutine, sending the buffered records and reconnecting
+// with an exponential backoff after a failure.
+func (w *NetWriter) schedule() {
+	timer := time.NewTimer(w.minBackoff)
+	stopTimer(timer) // start dormant; only fire after a failure
+	backoff := w.minBackoff
+	waiting := false
+
+	for {
+		select {
+		case <-w.updateChan:
+			if waiting { // the backoff timer will send them
+				continue
+			}
+		case <-timer.C:
+			waiting = false
+		case <-w.stopChan:
+			stopTimer(timer)
+			if err := w.send(); err != nil && !w.outage {
+				logError(err)
+			}
+			close(w.stoppedChan)
+			return
+		}
+
+		if err := w.send(); err != nil {
+			if !w.outage { // logs once per outage
+				w.outage = true
+				logError(err)
+			}
+			waiting = true
+			timer.Reset(backoff)
+			backoff *= 2
+			if backoff > w.maxBackoff {
+				backoff = w.maxBackoff
+			}
+		} else {
+			backoff = w.minBackoff
+		}
+	}
+}
+
+// send connects to the peer if needed, then sends the unsent records in order:
+// the ones failed to send last time, the spill file, and the pending buffer.
+// It should only be called by schedule().
+func (w *NetWriter) send() error {
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.addr, netTimeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		w.outage = false
+
+		w.lock.Lock()
+		dropped := w.dropped
+		w.dropped = 0
+		w.lock.Unlock()
+		if dropped > 0 {
+			logError(fmt.Errorf("dropped %d bytes while %s was unreachable", dropped, w.addr))
+		}
+	}
+
+	for {
+		if w.sending.Len() == 0 {
+			if err := w.fill(); err != nil {
+				return err
+			}
+			if w.sending.Len() == 0 {
+				return nil
+			}
+		}
+		n, err := w.writeConn(w.sending.Bytes())
+		w.sending.Next(frameStart(w.framing, w.sending.Bytes(), n)) // resends the partial frame
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// writeConn writes to the connection, closing it after a failure.
+func (w *NetWriter) writeConn(p []byte) (n int, err error) {
+	if err = w.conn.SetWriteDeadline(time.Now().Add(netTimeout)); err == nil {
+		n, err = w.conn.Write(p)
+	}
+	if err != nil {
+		if e := w.conn.Close(); e != nil {
+			logError(e)
+		}
+		w.conn = nil
+	}
+	return
+}
+
+// fill moves the next records to send into the empty sending buffer: the complete
+// frames following the sent part of the spill file, or the pending buffer after the
+// whole spill file was sent. Both are done within a lock block, so a Write spilling
+// the pending buffer cannot reorder the records.
+func (w *NetWriter) fill() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	remaining := w.spillSize - w.spillOffset
+	if remaining == 0 {
+		if w.spillSize > 0 {
+			w.spillSize = 0
+			w.spillOffset = 0
+			if err := w.spill.Truncate(0); err != nil {
+				return err
+			}
+		}
+		w.pending, w.sending = w.sending, w.pending
+		return nil
+	}
+
+	size := int64(netSpillReadSize)
+	for {
+		if size > remaining {
+			size = remaining
+		}
+		chunk := make([]byte, size)
+		if _, err := w.spill.ReadAt(chunk, w.spillOffset); err != nil {
+			return err
+		}
+		end := len(chunk)
+		if size < remaining {
+			end = frameStart(w.framing, chunk, end)
+			if end == 0 { // a frame larger than the chunk
+				size *= 2
+				continue
+			}
+		}
+		w.sending.Write(chunk[:end])
+		w.spillOffset += int64(end)
+		return nil
+	}
+}
+
+// frameStart returns the start of the frame which contains the nth byte of data,
+// or n if it's the start of a frame.
+func frameStart(framing Framing, data []byte, n int) int {
+	if framing == FramingLengthPrefix {
+		start := 0
+		for start+lengthPrefixSize <= n {
+			end := start + lengthPrefixSize + int(binary.BigEndian.Uint32(data[start:]))
+			if end > n {
+				break
+			}
+			start = end
+		}
+		return start
+	}
+	return bytes.LastIndexByte(data[:n], '\n') + 1
+}
+
+// Close sends the buffered records if connected, then closes the connection.
+// The unsent records are kept in the spill file if set, otherwise an error reports
+// how many bytes were not sent.
+// It's safe to call this method more than once.
+func (w *NetWriter) Close() error {
+	w.closeOnce.Do(func() {
+		w.lock.Lock()
+		w.closed = true
+		w.lock.Unlock()
+		close(w.stopChan)
+		<-w.stoppedChan // waits for schedule() to finish its last sending
+
+		if w.conn != nil {
+			if err := w.conn.Close(); err != nil {
+				logError(err)
+			}
+			w.conn = nil
+		}
+
+		unsent := int64(w.sending.Len()+w.pending.Len()) + w.spillSize - w.spillOffset
+		if w.spill == nil {
+			if unsent > 0 {
+				w.closeErr = fmt.Errorf("%d bytes were not sent to %s", unsent, w.addr)
+			}
+			return
+		}
+		if unsent == 0 {
+			w.closeErr = w.spill.Close()
+			if err := os.Remove(w.spillPath); err != nil && w.closeErr == nil {
+				w.closeErr = err
+			}
+		} else {
+			w.closeErr = w.compactSpill()
+		}
+		w.spill = nil
+	})
+	return w.closeErr
+}
+
+// compactSpill rewrites the spill file with the unsent records in order, so that the
+// next NetWriter can send them from the start of it.
+func (w *NetWriter) compactSpill() error {
+	tmpPath := w.spillPath + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fileMode)
+	if err == nil {
+		if _, err = f.Write(w.sending.Bytes()); err == nil {
+			section := io.NewSectionReader(w.spill, w.spillOffset, w.spillSize-w.spillOffset)
+			if _, err = io.Copy(f, section); err == nil {
+				_, err = f.Write(w.pending.Bytes())
+			}
+		}
+		if e := f.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := w.spill.Close(); e != nil {
+		logError(e)
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, w.spillPath)
+	}
+	return err
+}
@@ -0,0 +1,230 @@
+package golog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func acceptConn(t *testing.T, listener net.Listener) net.Conn {
+	t.Helper()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second * 5))
+	return conn
+}
+
+func readLines(t *testing.T, reader *bufio.Reader, count int) []string {
+	t.Helper()
+
+	lines := make([]string, count)
+	for i := range lines {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines[i] = strings.TrimSuffix(line, "\n")
+	}
+	return lines
+}
+
+func TestNetWriterNewlineFraming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	_, err = NewNetWriter("udp", listener.Addr().String())
+	if err == nil {
+		t.Error("NewNetWriter with udp network is invalid")
+	}
+
+	w, err := NewNetWriter("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := acceptConn(t, listener)
+	defer conn.Close()
+
+	l := NewLoggerWithWriter(w)
+	l.Info("first")
+	w.Write([]byte("second")) // a newline is added
+
+	lines := readLines(t, bufio.NewReader(conn), 2)
+	if !strings.HasSuffix(lines[0], "] first") || lines[1] != "second" {
+		t.Errorf("received %q", lines)
+	}
+
+	l.Close()
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetWriterLengthPrefixFraming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	w, err := NewNetWriter("unix", path, NetFraming(FramingLengthPrefix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn := acceptConn(t, listener)
+	defer conn.Close()
+
+	records := []string{"first\n", "multi\nline\n", ""}
+	for _, record := range records {
+		w.Write([]byte(record))
+	}
+	for _, record := range records {
+		var prefix [lengthPrefixSize]byte
+		if _, err := io.ReadFull(conn, prefix[:]); err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]byte, binary.BigEndian.Uint32(prefix[:]))
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			t.Fatal(err)
+		}
+		if expected := strings.TrimSuffix(record, "\n"); string(frame) != expected {
+			t.Errorf("received %q, expected %q", frame, expected)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNetWriterReconnect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	w, err := NewNetWriter("unix", path, NetBackoff(time.Millisecond*10, time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	conn := acceptConn(t, listener)
+	w.Write([]byte("1\n"))
+	if lines := readLines(t, bufio.NewReader(conn), 1); lines[0] != "1" {
+		t.Fatalf("received %q", lines)
+	}
+
+	// the peer restarts
+	conn.Close()
+	listener.Close()
+	for i := 2; i <= 10; i++ {
+		w.Write([]byte(strconv.Itoa(i) + "\n"))
+		time.Sleep(time.Millisecond * 5)
+	}
+	listener, err = net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	conn = acceptConn(t, listener)
+	defer conn.Close()
+	lines := readLines(t, bufio.NewReader(conn), 9)
+	for i, line := range lines {
+		if line != strconv.Itoa(i+2) {
+			t.Fatalf("received %q", lines)
+		}
+	}
+}
+
+func TestNetWriterSpillFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.sock")
+	spillPath := filepath.Join(dir, "spill.log")
+	const count = 100
+	record := strings.Repeat("x", 95)
+
+	// the peer is not started yet
+	w, err := NewNetWriter("unix", path, NetMemoryLimit(1024), NetSpillFile(spillPath, 1024*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < count; i++ {
+		w.Write([]byte(record + strconv.Itoa(1000+i) + "\n"))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, spillPath, int64(count*(len(record)+5)))
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer listener.Close()
+
+	// the next writer sends the spilled records first
+	w, err = NewNetWriter("unix", path, NetMemoryLimit(1024), NetSpillFile(spillPath, 1024*1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("last\n"))
+	conn := acceptConn(t, listener)
+	defer conn.Close()
+
+	lines := readLines(t, bufio.NewReader(conn), count+1)
+	for i, line := range lines[:count] {
+		if line != record+strconv.Itoa(1000+i) {
+			t.Fatalf("line %d is %q", i, line)
+		}
+	}
+	if lines[count] != "last" {
+		t.Errorf("last line is %q", lines[count])
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(spillPath); !os.IsNotExist(err) {
+		t.Errorf("empty spill file was not removed: %v", err)
+	}
+}
+
+func TestNetWriterDropWithoutSpillFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.sock")
+	w, err := NewNetWriter("unix", path, NetMemoryLimit(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	record := []byte(strings.Repeat("x", 99) + "\n")
+	for i := 0; i < 20; i++ {
+		if _, err := w.Write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Close()
+	if err == nil || !strings.Contains(err.Error(), "1000 bytes were not sent") {
+		t.Errorf("Close() error is %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package golog
+
+import (
+	"bytes"
+	"strings"
+	"sync/atomic"
+)
+
+// minPrettySourceWidth is the initial width of the source column of a pretty formatter.
+const minPrettySourceWidth = 12
+
+// NewPrettyFormatter creates a human-friendly formatter for the local development, e.g.
+//
+//	15:04:05 INFO  server:42     request done
+//	                             path   = /users
+//	                             status = 200
+//
+// The time, level and source are aligned in columns. The source column grows to the
+// longest source it has rendered.
+// The trailing key=value pairs of the message (logfmt style, the values can be quoted)
+// are rendered as fields on the following lines, with aligned keys. The continuation
+// lines of a multi-line message, and the stack trace (one line for the function and
+// one for the source of each frame), are indented to the message column.
+// It always renders the source, and should not be used for the production logs.
+func NewPrettyFormatter() *Formatter {
+	return &Formatter{
+		formatParts:  []FormatPart{&prettyFormatPart{}},
+		needsCaller:  true,
+		rendersStack: true,
+	}
+}
+
+// A prettyFormatPart renders the whole record for a pretty formatter.
+type prettyFormatPart struct {
+	sourceWidth atomic.Int32
+}
+
+// A prettyField is a key=value pair of the message.
+type prettyField struct {
+	key   string
+	value string
+}
+
+// Format writes the pretty record to the buf.
+func (p *prettyFormatPart) Format(r *Record, buf *bytes.Buffer) {
+	lineStart := buf.Len()
+	writeTime(r, buf)
+	buf.WriteByte(' ')
+	(&LongLevelFormatPart{padded: true}).Format(r, buf)
+	buf.WriteByte(' ')
+
+	sourceStart := buf.Len()
+	writeSource(r, buf)
+	sourceLen := int32(buf.Len() - sourceStart)
+	width := p.sourceWidth.Load()
+	for width < sourceLen && !p.sourceWidth.CompareAndSwap(width, sourceLen) {
+		width = p.sourceWidth.Load()
+	}
+	if width < minPrettySourceWidth {
+		width = minPrettySourceWidth
+	}
+	for i := sourceLen; i < width; i++ {
+		buf.WriteByte(' ')
+	}
+	buf.WriteString("  ")
+	indent := strings.Repeat(" ", buf.Len()-lineStart)
+
+	msg, fields := splitPrettyFields(r.Message())
+	for i, line := range strings.Split(msg, "\n") {
+		if i > 0 {
+			buf.WriteByte('\n')
+			buf.WriteString(indent)
+		}
+		buf.WriteString(line)
+	}
+
+	keyWidth := 0
+	for _, field := range fields {
+		if len(field.key) > keyWidth {
+			keyWidth = len(field.key)
+		}
+	}
+	for i, field := range fields {
+		if i > 0 || msg != "" { // the first field is on the first line if there is no message
+			buf.WriteByte('\n')
+			buf.WriteString(indent)
+		}
+		buf.WriteString(field.key)
+		for i := len(field.key); i < keyWidth; i++ {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(" = ")
+		buf.WriteString(field.value)
+	}
+
+	if r.stack != "" {
+		for _, line := range strings.Split(r.stack[1:], "\n") { // skips the leading newline
+			buf.WriteByte('\n')
+			buf.WriteString(indent)
+			tabs := len(line) - len(strings.TrimLeft(line, "\t"))
+			buf.WriteString(strings.Repeat("  ", tabs))
+			buf.WriteString(line[tabs:])
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+// splitPrettyFields splits the trailing key=value pairs from the last line of the
+// message. The values can be quoted by double quotes, which may contain spaces.
+func splitPrettyFields(msg string) (string, []prettyField) {
+	lineStart := strings.LastIndexByte(msg, '\n') + 1
+	line := msg[lineStart:]
+
+	var fields []prettyField
+	msgEnd := len(line)
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		start := i
+		eq := -1
+		for i < len(line) && line[i] != ' ' {
+			if line[i] == '=' && eq < 0 {
+				eq = i
+				if i+1 < len(line) && line[i+1] == '"' { // skips the quoted value
+					i += 2
+					for i < len(line) && line[i] != '"' {
+						if line[i] == '\\' {
+							i++
+						}
+						i++
+					}
+				}
+			}
+			i++
+		}
+		if i > len(line) {
+			i = len(line)
+		}
+		if eq > start && isPrettyFieldKey(line[start:eq]) {
+			if fields == nil {
+				msgEnd = start
+			}
+			fields = append(fields, prettyField{key: line[start:eq], value: line[eq+1 : i]})
+		} else if fields != nil { // not trailing, they are a part of the message
+			fields = nil
+			msgEnd = len(line)
+		}
+	}
+	return strings.TrimRight(msg[:lineStart+msgEnd], " "), fields
+}
+
+// isPrettyFieldKey reports whether the key consists of word bytes, dots and hyphens.
+func isPrettyFieldKey(key string) bool {
+	for i := 0; i < len(key); i++ {
+		if c := key[i]; !isWordByte(c) && c != '.' && c != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// isWordByte reports whether the byte is an ASCII letter, digit or underscore.
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
@@ -0,0 +1,75 @@
+package golog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitPrettyFields(t *testing.T) {
+	for _, c := range []struct {
+		msg    string
+		text   string
+		fields []prettyField
+	}{
+		{"hello world", "hello world", nil},
+		{"request done path=/users status=200", "request done", []prettyField{{"path", "/users"}, {"status", "200"}}},
+		{`failed err="no such file" retry.count=3`, "failed", []prettyField{{"err", `"no such file"`}, {"retry.count", "3"}}},
+		{"a=1 not a field b=2", "a=1 not a field", []prettyField{{"b", "2"}}},
+		{"x=1 y=2", "", []prettyField{{"x", "1"}, {"y", "2"}}},
+		{"line 1\nline 2 k=v", "line 1\nline 2", []prettyField{{"k", "v"}}},
+		{"1 + 1 =2", "1 + 1 =2", nil},
+		{`unterminated q="a b`, "unterminated", []prettyField{{"q", `"a b`}}},
+	} {
+		text, fields := splitPrettyFields(c.msg)
+		if text != c.text || len(fields) != len(c.fields) {
+			t.Errorf("split %q into %q, %v", c.msg, text, fields)
+			continue
+		}
+		for i, field := range fields {
+			if field != c.fields[i] {
+				t.Errorf("split %q into %q, %v", c.msg, text, fields)
+				break
+			}
+		}
+	}
+}
+
+func TestPrettyFormatter(t *testing.T) {
+	setNowFunc(func() time.Time { return time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC) })
+	defer setNowFunc(time.Now)
+
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, NewPrettyFormatter())
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	l.Log(InfoLevel, "/src/server.go", 42, "request done path=%s status=%d", "/users", 200)
+	l.Log(WarnLevel, "/src/a_very_long_file_name.go", 7, "line 1\nline 2")
+	l.Log(ErrorLevel, "/src/server.go", 8, "k=v")
+	expected := []string{
+		"15:04:05 INFO  server:42     request done",
+		"                             path   = /users",
+		"                             status = 200",
+		"15:04:05 WARN  a_very_long_file_name:7  line 1",
+		"                                        line 2",
+		"15:04:05 ERROR server:8                 k = v",
+	}
+	if s := w.String(); s != strings.Join(expected, "\n")+"\n" {
+		t.Errorf("output is\n%s", s)
+	}
+
+	w.Reset()
+	l.SetStackTraceLevel(ErrorLevel)
+	l.Error("failed")
+	lines := strings.Split(w.String(), "\n")
+	if len(lines) < 4 || !strings.HasSuffix(lines[0], "  failed") {
+		t.Fatalf("output is %q", w.String())
+	}
+	indent := strings.Repeat(" ", len(lines[0])-len("failed"))
+	if !strings.HasPrefix(lines[1], indent+"  github.com/keakon/golog.TestPrettyFormatter") ||
+		!strings.HasPrefix(lines[2], indent+"    ") || !strings.Contains(lines[2], "pretty_test.go:") {
+		t.Errorf("stack is %q", w.String())
+	}
+}
@@ -0,0 +1,121 @@
+package golog
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimitSummaryInterval is the interval of the records reporting how many records
+// were dropped by the rate limits. It's a var so tests can shorten it.
+var rateLimitSummaryInterval = time.Minute
+
+// A tokenBucket allows rate records per second, with bursts of at most burst records.
+type tokenBucket struct {
+	rate    float64
+	burst   float64
+	tokens  float64
+	last    time.Time // the time of the last take
+	dropped int       // the records dropped since the last summary
+}
+
+// take reports whether a token is available at t, and takes it if so.
+func (b *tokenBucket) take(t time.Time) bool {
+	if b.last.IsZero() {
+		b.tokens = b.burst
+	} else if elapsed := t.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = t
+	if b.tokens >= 1 {
+		b.tokens--
+		return true
+	}
+	b.dropped++
+	return false
+}
+
+// A rateLimiter holds the token buckets of the limited levels.
+type rateLimiter struct {
+	lock    sync.Mutex
+	buckets []*tokenBucket // indexed by level, nil for the unlimited levels
+	timer   *time.Timer    // reports the dropped counts, nil if no record was dropped since the last summary
+	stopped bool
+}
+
+// SetRateLimit limits the records of the level to rate records per second, allowing
+// bursts of at most burst records. The other levels are not limited by it, so e.g.
+// the debug and info records can be throttled under load while the error and critical
+// records always pass.
+// Every minute in which some records were dropped, a record of each throttled level
+// reports how many records of that level were dropped.
+// It can be called once for each limited level.
+func (h *Handler) SetRateLimit(lv Level, rate float64, burst int) {
+	if h.limiter == nil {
+		h.limiter = &rateLimiter{}
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	for int(lv) >= len(h.limiter.buckets) {
+		h.limiter.buckets = append(h.limiter.buckets, nil)
+	}
+	h.limiter.buckets[lv] = &tokenBucket{rate: rate, burst: float64(burst)}
+}
+
+// allow reports whether the record is allowed by the token bucket of its level.
+func (l *rateLimiter) allow(h *Handler, r *Record) bool {
+	if int(r.level) >= len(l.buckets) || l.buckets[r.level] == nil {
+		return true
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped || l.buckets[r.level].take(now()) {
+		return true
+	}
+	if l.timer == nil {
+		l.timer = time.AfterFunc(rateLimitSummaryInterval, func() { l.summarize(h) })
+	}
+	return false
+}
+
+// summarize is called by the timer to report the dropped counts.
+func (l *rateLimiter) summarize(h *Handler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if !l.stopped {
+		l.report(h)
+	}
+}
+
+// report outputs the dropped counts of the levels, then resets them.
+// It should be called within a lock block.
+func (l *rateLimiter) report(h *Handler) {
+	for lv, b := range l.buckets {
+		if b != nil && b.dropped > 0 {
+			h.outputSummary(Level(lv), "", 0, "rate limiting dropped %d records", b.dropped)
+			b.dropped = 0
+		}
+	}
+	l.timer = nil
+}
+
+// stop reports the dropped counts since the last summary, and stops limiting.
+func (l *rateLimiter) stop(h *Handler) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if l.stopped {
+		return
+	}
+	if l.timer != nil {
+		l.timer.Stop()
+	}
+	l.report(h)
+	l.stopped = true
+}
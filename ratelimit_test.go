@@ -0,0 +1,126 @@
+package golog
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	b := &tokenBucket{rate: 2, burst: 3}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		if !b.take(start) {
+			t.Fatalf("take %d failed in a burst", i)
+		}
+	}
+	if b.take(start) {
+		t.Error("took more than the burst")
+	}
+
+	tm := start.Add(time.Millisecond * 500) // 1 token
+	if !b.take(tm) || b.take(tm) {
+		t.Error("didn't take exactly 1 token after 0.5 second")
+	}
+
+	tm = tm.Add(time.Hour) // refilled to the burst
+	for i := 0; i < 3; i++ {
+		if !b.take(tm) {
+			t.Fatalf("take %d failed after refilling", i)
+		}
+	}
+	if b.take(tm.Add(-time.Second)) { // the clock went backwards
+		t.Error("took a token when the clock went backwards")
+	}
+	if b.dropped != 3 {
+		t.Errorf("dropped %d records, expected 3", b.dropped)
+	}
+}
+
+func TestHandlerRateLimit(t *testing.T) {
+	tm := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	setNowFunc(func() time.Time { return tm })
+	defer setNowFunc(time.Now)
+
+	l, h, w := newCaptureLogger("%l %m")
+	h.SetRateLimit(DebugLevel, 1, 2)
+	h.SetRateLimit(InfoLevel, 10, 1)
+
+	for i := 0; i < 5; i++ {
+		l.Debugf("d%d", i)
+		l.Infof("i%d", i)
+		l.Errorf("e%d", i) // not limited
+	}
+	tm = tm.Add(time.Second)
+	l.Debug("d5")
+	l.Info("i5")
+
+	expected := []string{
+		"D d0", "I i0", "E e0",
+		"D d1", "E e1",
+		"E e2",
+		"E e3",
+		"E e4",
+		"D d5", "I i5",
+	}
+	if s := w.String(); s != strings.Join(expected, "\n")+"\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	l.Close() // reports the dropped counts
+	if s := w.String(); s != "D rate limiting dropped 3 records\nI rate limiting dropped 4 records\n" {
+		t.Errorf("summaries are %q", s)
+	}
+}
+
+func TestHandlerRateLimitSummary(t *testing.T) {
+	interval := rateLimitSummaryInterval
+	rateLimitSummaryInterval = time.Millisecond * 20
+	defer func() { rateLimitSummaryInterval = interval }()
+
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	h.SetRateLimit(WarnLevel, 0.001, 1)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Warn("w")
+	}
+	time.Sleep(time.Millisecond * 100)
+	if s := w.String(); s != "W w\nW rate limiting dropped 2 records\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	l.Warn("w") // dropped in a new summary interval
+	time.Sleep(time.Millisecond * 100)
+	if s := w.String(); s != "W rate limiting dropped 1 records\n" {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestHandlerRateLimitSummaryCaller(t *testing.T) {
+	interval := rateLimitSummaryInterval
+	rateLimitSummaryInterval = time.Millisecond * 20
+	defer func() { rateLimitSummaryInterval = interval }()
+
+	l, h, w := newCaptureLogger("%f %s %m")
+	h.SetRateLimit(WarnLevel, 0.001, 1)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Warn("w") // the dropped records leave their pc in the pooled records
+	}
+	time.Sleep(time.Millisecond * 100)
+	lines := strings.Split(w.String(), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "TestHandlerRateLimitSummaryCaller ratelimit_test:") {
+		t.Fatalf("output is %q", lines)
+	}
+	if lines[1] != "??? ??? rate limiting dropped 2 records" {
+		t.Errorf("summary is %q", lines[1])
+	}
+}
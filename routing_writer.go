@@ -0,0 +1,107 @@
+package golog
+
+import "io"
+
+// A LevelRoutingWriter is a RecordWriter which writes each record to the writers of
+// the routes covering its level, so a single Handler can split its output by level
+// (e.g. Debug..Info to app.log and Warn..Fatal to app.err.log) without formatting the
+// record twice.
+//
+// Its routes should be added before adding it to a Handler, and not be changed after.
+type LevelRoutingWriter struct {
+	routes      []levelRoute
+	needsCaller bool
+}
+
+type levelRoute struct {
+	writer   io.WriteCloser
+	minLevel Level
+	maxLevel Level
+}
+
+// NewLevelRoutingWriter creates a new LevelRoutingWriter without any route.
+func NewLevelRoutingWriter() *LevelRoutingWriter {
+	return &LevelRoutingWriter{}
+}
+
+// AddRoute adds a route writing the records of minLevel..maxLevel (inclusive) to the
+// writer. The Write() method of the writer should be thread-safe. If the writer is a
+// RecordWriter, its WriteRecord() method will be called instead.
+// A writer can be added to several routes, and will only be closed once.
+func (w *LevelRoutingWriter) AddRoute(minLevel, maxLevel Level, writer io.WriteCloser) {
+	w.routes = append(w.routes, levelRoute{writer: writer, minLevel: minLevel, maxLevel: maxLevel})
+	if cw, ok := writer.(interface{ NeedsCaller() bool }); ok && cw.NeedsCaller() {
+		w.needsCaller = true
+	}
+}
+
+// Write writes p to the writers of the routes covering InfoLevel.
+func (w *LevelRoutingWriter) Write(p []byte) (n int, err error) {
+	return w.write(nil, InfoLevel, p)
+}
+
+// WriteRecord writes p to the writers of the routes covering the level of r.
+func (w *LevelRoutingWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	return w.write(r, r.level, p)
+}
+
+// write writes p to the matched writers, returning the first error.
+func (w *LevelRoutingWriter) write(r *Record, lv Level, p []byte) (n int, err error) {
+	for _, route := range w.routes {
+		if lv < route.minLevel || lv > route.maxLevel {
+			continue
+		}
+		var e error
+		if rw, ok := route.writer.(RecordWriter); ok && r != nil {
+			_, e = rw.WriteRecord(r, p)
+		} else {
+			_, e = route.writer.Write(p)
+		}
+		if e != nil && err == nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// NeedsCaller reports whether any of its writers needs the source of the records.
+func (w *LevelRoutingWriter) NeedsCaller() bool {
+	return w.needsCaller
+}
+
+// Flush flushes its writers having a Flush() error method, and returns the first error.
+func (w *LevelRoutingWriter) Flush() (err error) {
+	flushed := make(map[io.WriteCloser]struct{}, len(w.routes))
+	for _, route := range w.routes {
+		if _, ok := flushed[route.writer]; ok {
+			continue
+		}
+		flushed[route.writer] = struct{}{}
+		if f, ok := route.writer.(interface{ Flush() error }); ok {
+			if e := f.Flush(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return
+}
+
+// Close closes all its writers, and returns the first error.
+// It's safe to call this method more than once.
+func (w *LevelRoutingWriter) Close() (err error) {
+	closed := make(map[io.WriteCloser]struct{}, len(w.routes))
+	for _, route := range w.routes {
+		if _, ok := closed[route.writer]; ok {
+			continue
+		}
+		closed[route.writer] = struct{}{}
+		if e := route.writer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	w.routes = nil
+	return
+}
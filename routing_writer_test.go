@@ -0,0 +1,127 @@
+package golog
+
+import (
+	"errors"
+	"testing"
+)
+
+// closeCountWriter counts how many times it was closed.
+type closeCountWriter struct {
+	captureWriter
+	closed int
+}
+
+func (w *closeCountWriter) Close() error {
+	w.closed++
+	return nil
+}
+
+// failWriter fails every write.
+type failWriter struct{}
+
+func (w *failWriter) Write(p []byte) (n int, err error) {
+	return 0, errors.New("write failed")
+}
+
+func (w *failWriter) Close() error { return nil }
+
+func TestLevelRoutingWriter(t *testing.T) {
+	out := &closeCountWriter{}
+	errOut := &closeCountWriter{}
+	all := &closeCountWriter{}
+	w := NewLevelRoutingWriter()
+	w.AddRoute(DebugLevel, InfoLevel, out)
+	w.AddRoute(WarnLevel, CritLevel, errOut)
+	w.AddRoute(DebugLevel, CritLevel, all)
+	w.AddRoute(ErrorLevel, ErrorLevel, all) // a writer can be added to several routes
+
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	l.Debug("d")
+	l.Info("i")
+	l.Warn("w")
+	l.Error("e")
+	l.Crit("c")
+	w.Write([]byte("plain\n"))
+
+	if s := out.String(); s != "D d\nI i\nplain\n" {
+		t.Errorf("out is %q", s)
+	}
+	if s := errOut.String(); s != "W w\nE e\nC c\n" {
+		t.Errorf("errOut is %q", s)
+	}
+	if s := all.String(); s != "D d\nI i\nW w\nE e\nE e\nC c\nplain\n" {
+		t.Errorf("all is %q", s)
+	}
+
+	l.Close()
+	if out.closed != 1 || errOut.closed != 1 || all.closed != 1 {
+		t.Errorf("closed %d, %d and %d times", out.closed, errOut.closed, all.closed)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	if all.closed != 1 {
+		t.Errorf("closed %d times after closing twice", all.closed)
+	}
+}
+
+func TestLevelRoutingWriterRecordWriter(t *testing.T) {
+	journal := &JournalWriter{}
+	w := NewLevelRoutingWriter()
+	if w.NeedsCaller() {
+		t.Error("empty writer needs caller")
+	}
+	w.AddRoute(DebugLevel, CritLevel, &captureWriter{})
+	if w.NeedsCaller() {
+		t.Error("captureWriter needs caller")
+	}
+	w.AddRoute(WarnLevel, CritLevel, journal)
+	if !w.NeedsCaller() {
+		t.Error("JournalWriter doesn't need caller")
+	}
+
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	if !h.NeedsCaller() {
+		t.Error("handler doesn't need caller")
+	}
+
+	rw := &captureWriter{}
+	w = NewLevelRoutingWriter()
+	w.AddRoute(InfoLevel, ErrorLevel, rw)
+	h = NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	l.Debug("d")
+	l.Info("i")
+	l.Error("e")
+	l.Crit("c")
+	if len(rw.levels) != 2 || rw.levels[0] != InfoLevel || rw.levels[1] != ErrorLevel || rw.String() != "i\ne\n" {
+		t.Errorf("routed levels %v: %q", rw.levels, rw.String())
+	}
+}
+
+func TestLevelRoutingWriterError(t *testing.T) {
+	ok := &captureWriter{}
+	w := NewLevelRoutingWriter()
+	w.AddRoute(DebugLevel, CritLevel, &failWriter{})
+	w.AddRoute(DebugLevel, CritLevel, ok)
+
+	n, err := w.Write([]byte("test\n"))
+	if n != 0 || err == nil {
+		t.Errorf("Write() returned %d, %v", n, err)
+	}
+	if ok.String() != "test\n" {
+		t.Errorf("the other writer got %q", ok.String())
+	}
+
+	n, err = w.WriteRecord(&Record{level: DebugLevel}, []byte("test\n"))
+	if n != 0 || err == nil {
+		t.Errorf("WriteRecord() returned %d, %v", n, err)
+	}
+}
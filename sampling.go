@@ -0,0 +1,145 @@
+package golog
+
+import (
+	"sync"
+	"time"
+)
+
+// SamplingKey specifies how Handler.SetSampling groups the records.
+type SamplingKey uint8
+
+const (
+	// SampleByCallSite groups the records by their source files and lines.
+	SampleByCallSite SamplingKey = iota
+	// SampleByTemplate groups the records by their message templates, i.e. the msg of
+	// the xxxf methods, or the formatted message of the others.
+	SampleByTemplate
+)
+
+type sampleKey struct {
+	file     string
+	line     int
+	template string
+}
+
+type sampleCounter struct {
+	count      int
+	suppressed int
+	level      Level // the level of the last record
+}
+
+// A sampler logs the first records of each key per interval, then every thereafter-th.
+type sampler struct {
+	lock       sync.Mutex
+	key        SamplingKey
+	interval   time.Duration
+	first      int
+	thereafter int
+	counters   map[sampleKey]*sampleCounter
+	timer      *time.Timer // ends the current interval, nil if no record was sampled in it
+	stopped    bool
+}
+
+// SetSampling makes the handler log the first records of each call site (or message
+// template) per interval, then every thereafter-th of them (none if thereafter is 0).
+// At the end of each interval, a record of the same level reports how many records of
+// each call site (or template) were suppressed.
+// e.g. SetSampling(SampleByCallSite, time.Minute, 100, 1000) logs at most 100 records
+// per minute from each line, plus 1 in every 1000 after that.
+// This method should be called before Logger.AddHandler(), since the logger checks
+// whether the handler needs the caller when it's added, and sampling by call site does.
+func (h *Handler) SetSampling(key SamplingKey, interval time.Duration, first, thereafter int) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if first < 0 {
+		first = 0
+	}
+	if thereafter < 0 {
+		thereafter = 0
+	}
+	h.sampler = &sampler{
+		key:        key,
+		interval:   interval,
+		first:      first,
+		thereafter: thereafter,
+		counters:   map[sampleKey]*sampleCounter{},
+	}
+}
+
+// sample reports whether the record should be logged.
+func (s *sampler) sample(h *Handler, r *Record) bool {
+	var k sampleKey
+	if s.key == SampleByCallSite {
+		k.file = r.file
+		k.line = r.line
+	} else if r.message != "" {
+		k.template = r.message
+	} else {
+		k.template = r.Message()
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return true
+	}
+	if s.timer == nil {
+		s.timer = time.AfterFunc(s.interval, func() { s.endInterval(h) })
+	}
+	c := s.counters[k]
+	if c == nil {
+		c = &sampleCounter{}
+		s.counters[k] = c
+	}
+	c.count++
+	c.level = r.level
+	if c.count <= s.first || (s.thereafter > 0 && (c.count-s.first)%s.thereafter == 0) {
+		return true
+	}
+	c.suppressed++
+	return false
+}
+
+// endInterval is called by the timer at the end of an interval.
+func (s *sampler) endInterval(h *Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if !s.stopped {
+		s.report(h)
+	}
+}
+
+// report outputs the suppressed counts, then resets the counters.
+// It should be called within a lock block.
+func (s *sampler) report(h *Handler) {
+	for k, c := range s.counters {
+		if c.suppressed == 0 {
+			continue
+		}
+		if s.key == SampleByCallSite {
+			h.outputSummary(c.level, k.file, k.line, "sampling suppressed %d records of this call site", c.suppressed)
+		} else {
+			h.outputSummary(c.level, "", 0, "sampling suppressed %d records of %q", c.suppressed, k.template)
+		}
+	}
+	s.counters = map[sampleKey]*sampleCounter{}
+	s.timer = nil
+}
+
+// stop reports the suppressed counts of the current interval, and stops sampling.
+func (s *sampler) stop(h *Handler) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.stopped {
+		return
+	}
+	if s.timer != nil {
+		s.timer.Stop()
+	}
+	s.report(h)
+	s.stopped = true
+}
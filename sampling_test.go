@@ -0,0 +1,94 @@
+package golog
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSamplingByCallSite(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	h.SetSampling(SampleByCallSite, time.Hour, 2, 3)
+	if !h.NeedsCaller() {
+		t.Error("sampling by call site doesn't need caller")
+	}
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h) // after SetSampling(), so the caller is computed
+
+	for i := 0; i < 10; i++ {
+		l.Errorf("error %d", i)
+	}
+	l.Infof("error %d", 10) // another call site
+
+	expected := "E error 0\nE error 1\nE error 4\nE error 7\nI error 10\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+
+	l.Close() // reports the suppressed count
+	expected += "E sampling suppressed 6 records of this call site\n"
+	if s := w.String(); s != expected {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestSamplingByTemplate(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	h.SetSampling(SampleByTemplate, time.Millisecond*20, 1, 0)
+	if h.NeedsCaller() {
+		t.Error("sampling by template needs caller")
+	}
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	defer l.Close()
+
+	for i := 0; i < 3; i++ {
+		l.Warnf("retry %d", i)
+		l.Warnf("retry %d", i+10) // another call site with the same template
+		l.Warn("plain")
+	}
+	time.Sleep(time.Millisecond * 100) // the interval ended
+
+	lines := strings.Split(strings.TrimSuffix(w.String(), "\n"), "\n")
+	if len(lines) != 4 || lines[0] != "W retry 0" || lines[1] != "W plain" {
+		t.Fatalf("output is %q", lines)
+	}
+	summaries := map[string]bool{
+		`W sampling suppressed 5 records of "retry %d"`: true,
+		`W sampling suppressed 2 records of "plain"`:    true,
+	}
+	if !summaries[lines[2]] || !summaries[lines[3]] || lines[2] == lines[3] {
+		t.Errorf("summaries are %q", lines[2:])
+	}
+
+	w.Reset()
+	l.Warnf("retry %d", 20) // a new interval
+	if s := w.String(); s != "W retry 20\n" {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestSamplingDropAll(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	h.SetSampling(SampleByCallSite, time.Hour, 0, 0)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+
+	for i := 0; i < 10; i++ {
+		l.Info(strconv.Itoa(i))
+	}
+	if w.Len() != 0 {
+		t.Errorf("output is %q", w.String())
+	}
+	l.Close()
+	if s := w.String(); s != "sampling suppressed 10 records of this call site\n" {
+		t.Errorf("output is %q", s)
+	}
+}
@@ -0,0 +1,112 @@
+package golog
+
+import (
+	"path"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// sourceRoot is the prefix trimmed from the source files by the %R directive.
+	sourceRoot string
+
+	// relativeSources caches the relative paths of the sources, keyed by
+	// relativeSourceKey.
+	relativeSources sync.Map
+
+	// modulePaths are the paths of the main module and its dependencies, the longer ones
+	// first.
+	modulePaths     []string
+	modulePathsOnce sync.Once
+)
+
+// SetSourceRoot sets the prefix trimmed from the source file paths by the %R directive,
+// e.g. the root directory of the project on the build machine.
+// It's needed for the main packages built without -trimpath, whose module can't be
+// inferred from their package path "main".
+func SetSourceRoot(root string) {
+	if root != "" && !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	sourceRoot = root
+	relativeSources.Range(func(key, _ interface{}) bool {
+		relativeSources.Delete(key)
+		return true
+	})
+}
+
+// loadModulePaths loads the module paths from the build info.
+func loadModulePaths() {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if info.Main.Path != "" {
+		modulePaths = append(modulePaths, info.Main.Path)
+	}
+	for _, dep := range info.Deps {
+		modulePaths = append(modulePaths, dep.Path)
+	}
+	sort.Slice(modulePaths, func(i, j int) bool {
+		return len(modulePaths[i]) > len(modulePaths[j])
+	})
+}
+
+// A relativeSourceKey is the key of relativeSources.
+type relativeSourceKey struct {
+	file    string
+	pkgPath string
+}
+
+// relativeSource returns the path of the source file relative to its module root, e.g.
+// "internal/store/db.go". It's found in this order:
+//  1. the file path without the prefix set by SetSourceRoot();
+//  2. the file path without the module path and version, if built with -trimpath;
+//  3. the package path of its function without the module path, joined with the base
+//     name of the file;
+//  4. the base name of the file.
+func relativeSource(file, function string) string {
+	pkgPath, _ := splitFunctionName(function)
+	key := relativeSourceKey{file: file, pkgPath: pkgPath}
+	if rel, ok := relativeSources.Load(key); ok {
+		return rel.(string)
+	}
+	rel := findRelativeSource(file, pkgPath)
+	relativeSources.Store(key, rel)
+	return rel
+}
+
+func findRelativeSource(file, pkgPath string) string {
+	if sourceRoot != "" && strings.HasPrefix(file, sourceRoot) {
+		return file[len(sourceRoot):]
+	}
+
+	modulePathsOnce.Do(loadModulePaths)
+	for _, modPath := range modulePaths {
+		if len(file) > len(modPath) && strings.HasPrefix(file, modPath) {
+			switch rest := file[len(modPath):]; rest[0] {
+			case '/':
+				return rest[1:]
+			case '@': // a dependency: module@version/path
+				if i := strings.IndexByte(rest, '/'); i >= 0 {
+					return rest[i+1:]
+				}
+			}
+		}
+	}
+
+	base := path.Base(file)
+	if pkgPath != "" {
+		for _, modPath := range modulePaths {
+			if pkgPath == modPath {
+				return base
+			}
+			if strings.HasPrefix(pkgPath, modPath) && pkgPath[len(modPath)] == '/' {
+				return pkgPath[len(modPath)+1:] + "/" + base
+			}
+		}
+	}
+	return base
+}
@@ -0,0 +1,50 @@
+package golog
+
+import (
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestRelativeSource(t *testing.T) {
+	modulePathsOnce.Do(loadModulePaths)
+	paths := modulePaths
+	modulePaths = append([]string{"example.com/app/v2", "example.com/app", "example.com/dep"}, modulePaths...)
+	defer func() {
+		modulePaths = paths
+		SetSourceRoot("")
+	}()
+
+	for _, c := range []struct {
+		file     string
+		function string
+		expected string
+	}{
+		{"/home/ci/app/internal/store/db.go", "example.com/app/internal/store.(*DB).Query", "internal/store/db.go"}, // by the package path
+		{"/home/ci/app/cmd/server/main.go", "main.main", "main.go"},                                                 // unknown module
+		{"/home/ci/other.go", "", "other.go"},                                                                       // unknown function
+		{"example.com/app/internal/store/db.go", "", "internal/store/db.go"},                                        // -trimpath
+		{"example.com/app/v2/db.go", "", "db.go"},                                                                   // the longest module path
+		{"example.com/dep@v1.2.3/x/y.go", "", "x/y.go"},                                                             // a dependency with -trimpath
+	} {
+		if rel := relativeSource(c.file, c.function); rel != c.expected {
+			t.Errorf("relative source of %s is %s, expected %s", c.file, rel, c.expected)
+		}
+	}
+
+	SetSourceRoot("/home/ci/app")
+	if rel := relativeSource("/home/ci/app/cmd/server/main.go", "main.main"); rel != "cmd/server/main.go" {
+		t.Errorf("relative source is %s", rel)
+	}
+}
+
+func TestRelativeSourceFormatPart(t *testing.T) {
+	l, _, w := newCaptureLogger("%R %m")
+
+	l.Info("relative")
+	_, _, line, _ := runtime.Caller(0)
+	l.Log(InfoLevel, "", 0, "unknown")
+	if s := w.String(); s != "source_test.go:"+strconv.Itoa(line-1)+" relative\n??? unknown\n" {
+		t.Errorf("output is %q", s)
+	}
+}
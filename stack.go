@@ -0,0 +1,86 @@
+package golog
+
+import (
+	"reflect"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const maxStackDepth = 64
+
+var (
+	// loggerMethodPrefix and logFuncPrefixes are the function name prefixes of the logging
+	// methods of Logger and the logging functions (closures) of the log package, which are
+	// trimmed from the top of the stack traces.
+	// The closures are named "glob.funcN" or "init.funcN", depending on the Go version.
+	loggerMethodPrefix string
+	logFuncPrefixes    [3]string
+)
+
+func init() {
+	name := runtime.FuncForPC(reflect.ValueOf(Caller).Pointer()).Name() // e.g. github.com/keakon/golog.Caller
+	pkgPath := strings.TrimSuffix(name, ".Caller")
+	loggerMethodPrefix = pkgPath + ".(*Logger)."
+	logFuncPrefixes = [3]string{pkgPath + "/log.glob.", pkgPath + "/log.init.", pkgPath + "/log.LogAt"}
+}
+
+// SetStackTraceLevel makes the logger capture the stack trace of the records at or
+// above the level, e.g. ErrorLevel for the error and critical records.
+// A formatter renders it by the %t directive, or after the message if its format has
+// no %t directive. Each frame is rendered as its function and source in two lines,
+// indented by tabs.
+func (l *Logger) SetStackTraceLevel(lv Level) {
+	l.stackLevel = lv
+	l.captureStack = true
+}
+
+// callerStack returns the stack trace of the caller of Logger.Log, without the logging
+// methods and functions of this package.
+func callerStack() string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs) // skips runtime.Callers, callerStack and Logger.Log
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	top := true
+	for {
+		frame, more := frames.Next()
+		if top && isLoggingFunc(frame.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+		top = false
+		b.WriteString("\n\t")
+		b.WriteString(frame.Function)
+		b.WriteString("\n\t\t")
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
+
+// isLoggingFunc reports whether the function is a logging method or function of this package.
+func isLoggingFunc(name string) bool {
+	if strings.HasPrefix(name, loggerMethodPrefix) {
+		return true
+	}
+	for _, prefix := range logFuncPrefixes {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Stack returns the stack trace of the record, or an empty string if it's not captured.
+// Each frame is rendered as "\n\t" + function + "\n\t\t" + file:line.
+func (r *Record) Stack() string {
+	return r.stack
+}
@@ -0,0 +1,79 @@
+package golog
+
+import (
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStackTrace(t *testing.T) {
+	l, h, w := newCaptureLogger("%l %m")
+	l.SetStackTraceLevel(ErrorLevel)
+
+	l.Warn("no stack")
+	if s := w.String(); s != "W no stack\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	l.Errorf("with %s", "stack")
+	_, file, line, _ := runtime.Caller(0)
+	lines := strings.Split(w.String(), "\n")
+	if len(lines) < 5 || lines[0] != "E with stack" || lines[len(lines)-1] != "" {
+		t.Fatalf("output is %q", w.String())
+	}
+	if !strings.HasSuffix(lines[1], ".TestStackTrace") || !strings.HasPrefix(lines[1], "\t") {
+		t.Errorf("first frame is %q", lines[1])
+	}
+	if expected := "\t\t" + file + ":" + strconv.Itoa(line-1); lines[2] != expected {
+		t.Errorf("first source is %q, expected %q", lines[2], expected)
+	}
+	if !strings.Contains(w.String(), "testing.tRunner") {
+		t.Errorf("output has no caller of the test: %q", w.String())
+	}
+
+	// the directive renders the stack trace in place
+	w.Reset()
+	h.formatter = ParseFormat("%m%t|%l")
+	l.Crit("crit")
+	s := w.String()
+	if !strings.HasPrefix(s, "crit\n\t") || !strings.HasSuffix(s, "|C\n") {
+		t.Errorf("output is %q", s)
+	}
+
+	// the fast paths also render it
+	w.Reset()
+	h.formatter = DefaultFormatter
+	l.Error("default")
+	s = w.String()
+	if !strings.Contains(s, "] default\n\t") || !strings.HasSuffix(s, "\n") || strings.HasSuffix(s, "\n\n") {
+		t.Errorf("output is %q", s)
+	}
+}
+
+func TestStackTraceHTTPWriter(t *testing.T) {
+	s := newHTTPTestServer(t)
+	w, err := NewHTTPWriter(s.URL, HTTPBatchInterval(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	l.SetStackTraceLevel(DebugLevel)
+	l.Info("message")
+	l.Close()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.batches) != 1 || len(s.batches[0]) != 1 {
+		t.Fatalf("received %v", s.batches)
+	}
+	record := s.batches[0][0]
+	if record.Message != "message" || !strings.HasPrefix(record.Stack, "\t") || !strings.Contains(record.Stack, ".TestStackTraceHTTPWriter\n") {
+		t.Errorf("record is %+v", record)
+	}
+}
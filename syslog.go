@@ -0,0 +1,362 @@
+package golog
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	rfc5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+	rfc3164TimeFormat = time.Stamp
+
+	syslogNilValue = "-"
+)
+
+// syslogPaths are the usual paths of the local syslog daemon's Unix socket.
+var syslogPaths = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+// Facility specifies the syslog facility of the messages.
+type Facility uint8
+
+// All the syslog facilities defined by RFC 5424.
+const (
+	FacilityKern Facility = iota
+	FacilityUser
+	FacilityMail
+	FacilityDaemon
+	FacilityAuth
+	FacilitySyslog
+	FacilityLpr
+	FacilityNews
+	FacilityUucp
+	FacilityCron
+	FacilityAuthpriv
+	FacilityFtp
+	FacilityNtp
+	FacilityAudit
+	FacilityAlert
+	FacilityClock
+	FacilityLocal0
+	FacilityLocal1
+	FacilityLocal2
+	FacilityLocal3
+	FacilityLocal4
+	FacilityLocal5
+	FacilityLocal6
+	FacilityLocal7
+)
+
+// The syslog severities used by SyslogWriter.
+const (
+	syslogAlert   = 1
+	syslogCrit    = 2
+	syslogErr     = 3
+	syslogWarning = 4
+	syslogNotice  = 5
+	syslogInfo    = 6
+	syslogDebug   = 7
+)
+
+// syslogSeverity maps a log level to a syslog severity.
+// A custom level is mapped to the severity of the nearest lower built-in level, or
+// notice if it's between the info and warning levels.
+func syslogSeverity(lv Level) int {
+	switch {
+	case lv < InfoLevel:
+		return syslogDebug
+	case lv == InfoLevel:
+		return syslogInfo
+	case lv < WarnLevel:
+		return syslogNotice
+	case lv < ErrorLevel:
+		return syslogWarning
+	case lv < CritLevel:
+		return syslogErr
+	case lv < PanicLevel:
+		return syslogCrit
+	default:
+		return syslogAlert
+	}
+}
+
+// A SyslogWriter is a RecordWriter which sends the records to a syslog daemon.
+// Each record becomes a syslog message whose severity is mapped from its level.
+// The messages are formatted by RFC 5424 by default, or by RFC 3164 with the
+// SyslogRFC3164 option. Over a stream connection, RFC 5424 messages are framed by
+// octet counting and RFC 3164 messages are terminated by a newline (RFC 6587).
+type SyslogWriter struct {
+	lock           sync.Mutex
+	network        string
+	addr           string
+	conn           net.Conn
+	buf            bytes.Buffer
+	hostname       string
+	appName        string
+	procID         string
+	structuredData string
+	facility       Facility
+	rfc3164        bool
+	local          bool
+	closed         bool
+}
+
+// SyslogOption is an option of SyslogWriter.
+type SyslogOption func(*SyslogWriter)
+
+// SyslogFacility sets the facility of the messages. It defaults to FacilityUser.
+func SyslogFacility(facility Facility) SyslogOption {
+	return func(w *SyslogWriter) {
+		if facility <= FacilityLocal7 {
+			w.facility = facility
+		}
+	}
+}
+
+// SyslogAppName sets the APP-NAME (or the TAG of RFC 3164) of the messages.
+// It defaults to the base name of the executable.
+func SyslogAppName(name string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.appName = syslogHeaderField(name, 48)
+	}
+}
+
+// SyslogHostname sets the HOSTNAME of the messages. It defaults to os.Hostname().
+func SyslogHostname(name string) SyslogOption {
+	return func(w *SyslogWriter) {
+		w.hostname = syslogHeaderField(name, 255)
+	}
+}
+
+// SyslogRFC3164 formats the messages by RFC 3164 (the BSD syslog protocol) instead of
+// RFC 5424, for the daemons which do not understand the latter.
+func SyslogRFC3164() SyslogOption {
+	return func(w *SyslogWriter) {
+		w.rfc3164 = true
+	}
+}
+
+// SyslogStructuredData adds an RFC 5424 structured data element to every message.
+// The params are pairs of names and values. It's ignored by RFC 3164.
+func SyslogStructuredData(id string, params ...string) SyslogOption {
+	return func(w *SyslogWriter) {
+		var buf bytes.Buffer
+		buf.WriteString(w.structuredData)
+		buf.WriteByte('[')
+		buf.WriteString(syslogName(id, 32))
+		for i := 0; i+1 < len(params); i += 2 {
+			buf.WriteByte(' ')
+			buf.WriteString(syslogName(params[i], 32))
+			buf.WriteString(`="`)
+			for _, c := range []byte(params[i+1]) {
+				if c == '"' || c == '\\' || c == ']' {
+					buf.WriteByte('\\')
+				}
+				buf.WriteByte(c)
+			}
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+		w.structuredData = buf.String()
+	}
+}
+
+// NewSyslogWriter creates a new SyslogWriter connected to the syslog daemon at addr.
+// The network can be "udp", "tcp", "unixgram" or "unix". If it's empty, the writer
+// connects to the local syslog daemon through the Unix socket at addr, or at the
+// usual paths if addr is empty too.
+func NewSyslogWriter(network, addr string, options ...SyslogOption) (*SyslogWriter, error) {
+	w := &SyslogWriter{
+		network:  network,
+		addr:     addr,
+		facility: FacilityUser,
+		appName:  syslogHeaderField(filepath.Base(os.Args[0]), 48),
+		procID:   strconv.Itoa(os.Getpid()),
+		local:    network == "",
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		w.hostname = syslogHeaderField(hostname, 255)
+	}
+	for _, option := range options {
+		option(w)
+	}
+
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// connect connects to the syslog daemon. It should be called within a lock block.
+func (w *SyslogWriter) connect() (err error) {
+	if !w.local {
+		w.conn, err = net.Dial(w.network, w.addr)
+		return
+	}
+
+	paths := syslogPaths
+	if w.addr != "" {
+		paths = []string{w.addr}
+	}
+	for _, path := range paths {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			conn, err = net.Dial(network, path)
+			if err == nil {
+				w.conn = conn
+				w.network = network
+				return
+			}
+		}
+	}
+	if err == nil {
+		err = errors.New("no syslog socket found")
+	}
+	return
+}
+
+// Write sends p as a message of the info severity.
+func (w *SyslogWriter) Write(p []byte) (n int, err error) {
+	return w.write(syslogInfo, p)
+}
+
+// WriteRecord sends p as a message whose severity is mapped from the record's level.
+func (w *SyslogWriter) WriteRecord(r *Record, p []byte) (n int, err error) {
+	return w.write(syslogSeverity(r.level), p)
+}
+
+// write sends a message, reconnecting once if the connection was broken.
+func (w *SyslogWriter) write(severity int, p []byte) (n int, err error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	w.format(severity, p)
+	if w.conn != nil {
+		if _, err = w.conn.Write(w.buf.Bytes()); err == nil {
+			return len(p), nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	if err = w.connect(); err != nil {
+		return 0, err
+	}
+	if _, err = w.conn.Write(w.buf.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// format formats a message into w.buf.
+func (w *SyslogWriter) format(severity int, p []byte) {
+	p = bytes.TrimSuffix(p, []byte{'\n'})
+	stream := w.network == "tcp" || w.network == "unix"
+	tm := now()
+
+	w.buf.Reset()
+	if stream && !w.rfc3164 {
+		w.buf.WriteString("0000000000 ") // reserves the octet count
+	}
+	start := w.buf.Len()
+
+	w.buf.WriteByte('<')
+	writeUintToBuf(&w.buf, int(w.facility)*8+severity)
+	w.buf.WriteByte('>')
+	if w.rfc3164 {
+		w.buf.WriteString(tm.Format(rfc3164TimeFormat))
+		w.buf.WriteByte(' ')
+		if !w.local && w.hostname != "" { // local daemons add the hostname themselves
+			w.buf.WriteString(w.hostname)
+			w.buf.WriteByte(' ')
+		}
+		w.buf.WriteString(w.appName)
+		w.buf.WriteByte('[')
+		w.buf.WriteString(w.procID)
+		w.buf.WriteString("]: ")
+	} else {
+		w.buf.WriteString("1 ")
+		w.buf.WriteString(tm.Format(rfc5424TimeFormat))
+		w.buf.WriteByte(' ')
+		writeSyslogField(&w.buf, w.hostname)
+		w.buf.WriteByte(' ')
+		writeSyslogField(&w.buf, w.appName)
+		w.buf.WriteByte(' ')
+		w.buf.WriteString(w.procID)
+		w.buf.WriteString(" - ") // MSGID
+		writeSyslogField(&w.buf, w.structuredData)
+		w.buf.WriteByte(' ')
+	}
+	w.buf.Write(p)
+
+	if stream {
+		if w.rfc3164 {
+			w.buf.WriteByte('\n')
+		} else {
+			length := strconv.Itoa(w.buf.Len() - start)
+			w.buf.Next(start - len(length) - 1) // skips the unused reserved bytes
+			copy(w.buf.Bytes(), length)
+		}
+	}
+}
+
+// Close closes the connection.
+// It's safe to call this method more than once.
+func (w *SyslogWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+func writeSyslogField(buf *bytes.Buffer, s string) {
+	if s == "" {
+		buf.WriteString(syslogNilValue)
+	} else {
+		buf.WriteString(s)
+	}
+}
+
+// syslogHeaderField converts s to an RFC 5424 header field of at most max printable
+// US-ASCII characters. Other characters are replaced with '_'.
+func syslogHeaderField(s string, max int) string {
+	if len(s) > max {
+		s = s[:max]
+	}
+	bs := []byte(s)
+	for i, c := range bs {
+		if c < 33 || c > 126 {
+			bs[i] = '_'
+		}
+	}
+	return string(bs)
+}
+
+// syslogName converts s to an RFC 5424 SD-NAME, which additionally excludes '=', ']' and '"'.
+func syslogName(s string, max int) string {
+	bs := []byte(syslogHeaderField(s, max))
+	for i, c := range bs {
+		if c == '=' || c == ']' || c == '"' {
+			bs[i] = '_'
+		}
+	}
+	return string(bs)
+}
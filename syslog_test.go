@@ -0,0 +1,191 @@
+package golog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newSyslogTestLogger(w *SyslogWriter) *Logger {
+	h := NewHandler(DebugLevel, ParseFormat("%m"))
+	h.AddWriter(w)
+	l := NewLogger(DebugLevel)
+	l.AddHandler(h)
+	return l
+}
+
+func TestSyslogSeverity(t *testing.T) {
+	severities := map[Level]int{
+		DebugLevel: 7,
+		InfoLevel:  6,
+		WarnLevel:  4,
+		ErrorLevel: 3,
+		CritLevel:  2,
+		PanicLevel: 1,
+		FatalLevel: 1,
+		5:          7, // trace
+		25:         5, // notice
+		45:         3,
+	}
+	for level, severity := range severities {
+		if s := syslogSeverity(level); s != severity {
+			t.Errorf("severity of level %d is %d, expected %d", level, s, severity)
+		}
+	}
+}
+
+func TestSyslogWriterUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("udp", conn.LocalAddr().String(),
+		SyslogFacility(FacilityLocal0), SyslogAppName("my app"), SyslogHostname("host"),
+		SyslogStructuredData("meta@32473", "env", `prod "eu"`, "zone", "a]b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newSyslogTestLogger(w)
+	defer l.Close()
+
+	l.Warn("hello")
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := string(buf[:n])
+	prefix := "<132>1 " // local0 * 8 + warning
+	if !strings.HasPrefix(msg, prefix) {
+		t.Fatalf("message is %q, expected prefix %q", msg, prefix)
+	}
+	fields := strings.SplitN(msg[len(prefix):], " ", 6)
+	if len(fields) != 6 {
+		t.Fatalf("message is %q", msg)
+	}
+	if _, err := time.Parse(rfc5424TimeFormat, fields[0]); err != nil {
+		t.Errorf("invalid timestamp: %v", err)
+	}
+	if fields[1] != "host" || fields[2] != "my_app" || fields[3] != strconv.Itoa(os.Getpid()) || fields[4] != "-" {
+		t.Errorf("header is %q", fields[:5])
+	}
+	expected := `[meta@32473 env="prod \"eu\"" zone="a\]b"] hello`
+	if fields[5] != expected {
+		t.Errorf("structured data and message are %q, expected %q", fields[5], expected)
+	}
+}
+
+func TestSyslogWriterTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	w, err := NewSyslogWriter("tcp", listener.Addr().String(), SyslogHostname(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l := newSyslogTestLogger(w)
+	l.Error("first")
+	l.Debugf("second %d", 2)
+	l.Close()
+
+	reader := bufio.NewReader(conn)
+	for _, expected := range []struct {
+		prefix string
+		msg    string
+	}{
+		{"<11>1 ", "- first"},
+		{"<15>1 ", "- second 2"},
+	} {
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := make([]byte, size)
+		if _, err := io.ReadFull(reader, frame); err != nil {
+			t.Fatal(err)
+		}
+		msg := string(frame)
+		if !strings.HasPrefix(msg, expected.prefix) || !strings.HasSuffix(msg, expected.msg) {
+			t.Errorf("message is %q", msg)
+		}
+		fields := strings.Split(msg, " ")
+		if len(fields) < 7 || fields[2] != "-" || fields[5] != "-" || fields[6] != "-" { // nil HOSTNAME, MSGID and STRUCTURED-DATA
+			t.Errorf("message is %q", msg)
+		}
+	}
+
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSyslogWriterLocalRFC3164(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skip(err)
+	}
+	defer conn.Close()
+
+	w, err := NewSyslogWriter("", path, SyslogRFC3164(), SyslogAppName("app"), SyslogFacility(FacilityDaemon))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := newSyslogTestLogger(w)
+	defer l.Close()
+
+	l.Info("hello\n")
+	buf := make([]byte, 1024)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := string(buf[:n])
+	if !strings.HasPrefix(msg, "<30>") { // daemon * 8 + info
+		t.Fatalf("message is %q", msg)
+	}
+	timestamp := msg[4 : 4+len(rfc3164TimeFormat)]
+	if _, err := time.Parse(rfc3164TimeFormat, timestamp); err != nil {
+		t.Errorf("invalid timestamp: %v", err)
+	}
+	expected := " app[" + strconv.Itoa(os.Getpid()) + "]: hello\n" // only the newline added by the formatter is trimmed
+	if msg[4+len(rfc3164TimeFormat):] != expected {
+		t.Errorf("message is %q, expected suffix %q", msg, expected)
+	}
+}
+
+func TestSyslogWriterNoLocalSocket(t *testing.T) {
+	_, err := NewSyslogWriter("", filepath.Join(t.TempDir(), "missing.sock"))
+	if err == nil {
+		t.Error("connected to a missing socket")
+	}
+}
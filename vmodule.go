@@ -0,0 +1,92 @@
+package golog
+
+import (
+	"errors"
+	"path"
+	"strings"
+	"sync"
+)
+
+// A vmodule holds the levels of the source files matching its patterns.
+type vmodule struct {
+	rules    []vmoduleRule
+	minLevel Level    // the lowest level of the rules
+	maxLevel Level    // the highest level of the rules
+	levels   sync.Map // the cached levels by file path, nil for the unmatched files
+}
+
+type vmoduleRule struct {
+	pattern string
+	depth   int // the count of the path components matched by the pattern
+	level   Level
+}
+
+// parseVModule parses a comma separated list of pattern=level.
+func parseVModule(spec string) (*vmodule, error) {
+	v := &vmodule{minLevel: disabledLevel}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		i := strings.LastIndexByte(item, '=')
+		if i <= 0 {
+			return nil, errors.New("invalid vmodule item: " + item)
+		}
+		pattern := strings.TrimSuffix(item[:i], ".go")
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, errors.New("invalid vmodule pattern: " + pattern)
+		}
+		lv, err := ParseLevel(item[i+1:])
+		if err != nil {
+			return nil, errors.New("invalid vmodule level: " + item[i+1:])
+		}
+
+		v.rules = append(v.rules, vmoduleRule{pattern: pattern, depth: strings.Count(pattern, "/") + 1, level: lv})
+		if lv < v.minLevel {
+			v.minLevel = lv
+		}
+		if lv > v.maxLevel {
+			v.maxLevel = lv
+		}
+	}
+	if len(v.rules) == 0 {
+		return nil, nil
+	}
+	return v, nil
+}
+
+// level returns the level of the source file, or defaultLevel if no pattern matches it.
+func (v *vmodule) level(file string, defaultLevel Level) Level {
+	if file == "" {
+		return defaultLevel
+	}
+	if lv, ok := v.levels.Load(file); ok {
+		if lv == nil {
+			return defaultLevel
+		}
+		return lv.(Level)
+	}
+
+	name := strings.TrimSuffix(file, ".go")
+	for _, rule := range v.rules {
+		if matched, _ := path.Match(rule.pattern, lastPathComponents(name, rule.depth)); matched {
+			v.levels.Store(file, rule.level)
+			return rule.level
+		}
+	}
+	v.levels.Store(file, nil)
+	return defaultLevel
+}
+
+// lastPathComponents returns the last n components of the slash-separated path.
+func lastPathComponents(name string, n int) string {
+	i := len(name)
+	for ; n > 0; n-- {
+		i = strings.LastIndexByte(name[:i], '/')
+		if i < 0 {
+			return name
+		}
+	}
+	return name[i+1:]
+}
@@ -0,0 +1,119 @@
+package golog
+
+import "testing"
+
+func TestParseVModule(t *testing.T) {
+	v, err := parseVModule(" writer*=debug, net/*.go=WARN ,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(v.rules) != 2 || v.minLevel != DebugLevel || v.maxLevel != WarnLevel {
+		t.Fatalf("vmodule is %+v", v)
+	}
+	if rule := v.rules[1]; rule.pattern != "net/*" || rule.depth != 2 || rule.level != WarnLevel {
+		t.Errorf("rule is %+v", rule)
+	}
+
+	if v, err = parseVModule(""); v != nil || err != nil {
+		t.Errorf("empty spec returned %v, %v", v, err)
+	}
+	for _, spec := range []string{"writer", "=debug", "writer=verbose", "[=debug"} {
+		if _, err := parseVModule(spec); err == nil {
+			t.Errorf("parsed invalid spec %q", spec)
+		}
+	}
+}
+
+func TestLastPathComponents(t *testing.T) {
+	cases := []struct {
+		name     string
+		n        int
+		expected string
+	}{
+		{"/src/net/conn", 1, "conn"},
+		{"/src/net/conn", 2, "net/conn"},
+		{"src/net/conn", 3, "src/net/conn"},
+		{"src/net/conn", 5, "src/net/conn"},
+		{"conn", 1, "conn"},
+	}
+	for _, c := range cases {
+		if result := lastPathComponents(c.name, c.n); result != c.expected {
+			t.Errorf("last %d components of %q are %q, expected %q", c.n, c.name, result, c.expected)
+		}
+	}
+}
+
+func TestVModuleLevel(t *testing.T) {
+	v, err := parseVModule("writer*=debug,net/*=warn,vendor/*/*=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		file     string
+		expected Level
+	}{
+		{"/src/golog/writer.go", DebugLevel},
+		{"/src/golog/writer_test.go", DebugLevel},
+		{"/src/golog/net/conn.go", WarnLevel},
+		{"/src/golog/net_writer.go", InfoLevel}, // the base name is not matched by net/*
+		{"/src/vendor/dep/client.go", ErrorLevel},
+		{"/src/golog/logger.go", InfoLevel},
+		{"", InfoLevel},
+	}
+	for i := 0; i < 2; i++ { // checks the cached levels too
+		for _, c := range cases {
+			if lv := v.level(c.file, InfoLevel); lv != c.expected {
+				t.Errorf("level of %q is %d, expected %d", c.file, lv, c.expected)
+			}
+		}
+	}
+}
+
+func TestLoggerVModule(t *testing.T) {
+	w := &captureWriter{}
+	h := NewHandler(DebugLevel, ParseFormat("%l %m"))
+	h.AddWriter(w)
+	l := NewLogger(InfoLevel)
+	l.AddHandler(h)
+	if l.NeedsCaller() || l.IsEnabledFor(DebugLevel) {
+		t.Fatal("the logger needs caller or is enabled for debug level")
+	}
+
+	if err := l.SetVModule("vmodule_test=debug,/src/chatty/*=error"); err != nil {
+		t.Fatal(err)
+	}
+	if !l.NeedsCaller() || !l.IsEnabledFor(DebugLevel) {
+		t.Fatal("the logger doesn't need caller or is not enabled for debug level")
+	}
+	l.Debug("1")
+	l.Log(DebugLevel, "/src/other/main.go", 1, "2")
+	l.Log(InfoLevel, "/src/other/main.go", 1, "3")
+	l.Log(WarnLevel, "/src/chatty/client.go", 1, "4")
+	l.Log(ErrorLevel, "/src/chatty/client.go", 1, "5")
+	if s := w.String(); s != "D 1\nI 3\nE 5\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	w.Reset()
+	if err := l.SetVModule("invalid"); err == nil {
+		t.Error("set an invalid spec")
+	}
+	if err := l.SetVModule(""); err != nil {
+		t.Fatal(err)
+	}
+	if l.NeedsCaller() || l.IsEnabledFor(DebugLevel) {
+		t.Error("the logger needs caller or is enabled for debug level after removing vmodule")
+	}
+	l.Log(WarnLevel, "/src/chatty/client.go", 1, "6")
+	if s := w.String(); s != "W 6\n" {
+		t.Errorf("output is %q", s)
+	}
+
+	// the handlers' levels are kept
+	l = NewLogger(InfoLevel)
+	l.AddHandler(NewHandler(WarnLevel, nil))
+	l.SetVModule("*=debug")
+	if l.IsEnabledFor(InfoLevel) {
+		t.Error("the logger is enabled for a level lower than its handlers")
+	}
+}
@@ -60,23 +60,35 @@ func (w *DiscardWriter) Close() error {
 }
 
 // A ConsoleWriter is a writer which should not be actually closed.
+// It's a RecordWriter, which colors the records by their levels if its color mode is set.
 type ConsoleWriter struct {
 	*os.File // faster than io.Writer
+	color    ColorMode
 }
 
+// ConsoleWriterOption is an option of ConsoleWriter.
+type ConsoleWriterOption func(*ConsoleWriter)
+
 // NewConsoleWriter creates a new ConsoleWriter.
-func NewConsoleWriter(f *os.File) *ConsoleWriter {
-	return &ConsoleWriter{File: f}
+func NewConsoleWriter(f *os.File, options ...ConsoleWriterOption) *ConsoleWriter {
+	w := &ConsoleWriter{File: f}
+	for _, option := range options {
+		option(w)
+	}
+	if w.color != ColorNone && !colorEnabled(f) {
+		w.color = ColorNone
+	}
+	return w
 }
 
 // NewStdoutWriter creates a new stdout writer.
-func NewStdoutWriter() *ConsoleWriter {
-	return NewConsoleWriter(os.Stdout)
+func NewStdoutWriter(options ...ConsoleWriterOption) *ConsoleWriter {
+	return NewConsoleWriter(os.Stdout, options...)
 }
 
 // NewStderrWriter creates a new stderr writer.
-func NewStderrWriter() *ConsoleWriter {
-	return NewConsoleWriter(os.Stderr)
+func NewStderrWriter(options ...ConsoleWriterOption) *ConsoleWriter {
+	return NewConsoleWriter(os.Stderr, options...)
 }
 
 // Close does nothing.
@@ -93,6 +105,10 @@ type bufferedFileWriter struct {
 	file       *os.File
 	buffer     *bufio.Writer
 	bufferSize uint32
+	// The fields below are only used by ConcurrentFileWriter.
+	highWaterMark uint32
+	blocksWrites  bool // whether the writes block beyond twice the highWaterMark
+	ordered       bool
 }
 
 type BufferedFileWriterOption func(*bufferedFileWriter)
@@ -106,6 +122,34 @@ func BufferSize(size uint32) BufferedFileWriterOption {
 	}
 }
 
+// ShardHighWaterMark sets the high-water mark of a ConcurrentFileWriter's shard buffers,
+// and makes a write that would grow a non-empty shard beyond twice of it block until the
+// shard is drained, so the memory used by the shards stays bounded under extreme load.
+// Without it, the high-water mark is the initial capacity of each shard
+// (bufferSize/GOMAXPROCS, at least 4 KB), and the writes never block.
+// Either way, a shard reaching it is drained early instead of waiting for the next
+// 0.1 second tick.
+// The other writers flush when reaching their buffer capacity, so it has no effect on them.
+func ShardHighWaterMark(size uint32) BufferedFileWriterOption {
+	return func(w *bufferedFileWriter) {
+		if size >= 1024 {
+			w.highWaterMark = size
+			w.blocksWrites = true
+		}
+	}
+}
+
+// Ordered makes a ConcurrentFileWriter write the records in the order of their Write()
+// calls, instead of in a random order across shards within each flush cycle.
+// Each write is tagged with a global sequence number (one shared atomic add), and the
+// shards are merged by it when flushing. The other writers are always ordered, so it
+// has no effect on them.
+func Ordered() BufferedFileWriterOption {
+	return func(w *bufferedFileWriter) {
+		w.ordered = true
+	}
+}
+
 // A BufferedFileWriter is a buffered file writer.
 // The written bytes will be flushed to the log file every 0.1 second,
 // or when reaching the buffer capacity (4 MB).
@@ -203,6 +247,16 @@ func (w *BufferedFileWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
+// Flush flushes the buffer to the file without waiting for the next 0.1 second tick.
+func (w *BufferedFileWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.file == nil {
+		return os.ErrClosed
+	}
+	return w.buffer.Flush()
+}
+
 // Close flushes the buffer, then closes the file writer. Idempotent.
 //
 // Concurrent Close calls are serialised: the first one stops the schedule goroutine,
@@ -334,16 +388,7 @@ func (w *RotatingFileWriter) rotate() error {
 		return err
 	}
 
-	for i := w.backupCount; i > 1; i-- {
-		oldPath := fmt.Sprintf("%s.%d", w.path, i-1)
-		newPath := fmt.Sprintf("%s.%d", w.path, i)
-		e := os.Rename(oldPath, newPath)
-		if e != nil && !os.IsNotExist(e) {
-			logError(e)
-		}
-	}
-
-	err = os.Rename(w.path, w.path+".1")
+	err = renameBackups(w.path, w.backupCount)
 	if err != nil {
 		w.file = nil
 		w.buffer = nil
@@ -362,6 +407,20 @@ func (w *RotatingFileWriter) rotate() error {
 	return nil
 }
 
+// renameBackups shifts the backups of path by one (path.1 to path.2 and so on),
+// dropping the oldest one beyond backupCount, then renames path to path.1.
+func renameBackups(path string, backupCount uint8) error {
+	for i := backupCount; i > 1; i-- {
+		oldPath := fmt.Sprintf("%s.%d", path, i-1)
+		newPath := fmt.Sprintf("%s.%d", path, i)
+		e := os.Rename(oldPath, newPath)
+		if e != nil && !os.IsNotExist(e) {
+			logError(e)
+		}
+	}
+	return os.Rename(path, path+".1")
+}
+
 // A TimedRotatingFileWriter is a buffered file writer which will rotate by time.
 // Its rotateDuration can be either RotateByDate or RotateByHour.
 // It keeps at most backupCount backups.
@@ -472,21 +531,31 @@ func (w *TimedRotatingFileWriter) rotate(timer *time.Timer) error {
 
 // purge removes the outdated backups.
 func (w *TimedRotatingFileWriter) purge() {
-	pathes, err := filepath.Glob(w.pathPrefix + "*")
-	if err != nil {
-		logError(err)
-		return
-	}
-
-	pathes = filterTimedRotatingFiles(pathes, w.pathPrefix, w.rotateDuration)
-	count := len(pathes) - int(w.backupCount) - 1
-	if count > 0 {
+	purgeTimedRotatingFiles(w.pathPrefix, w.rotateDuration, w.backupCount, func() string {
 		var name string
 		w.lock.Lock()
 		if w.file != nil { // not closed
 			name = w.file.Name()
 		}
 		w.lock.Unlock()
+		return name
+	})
+}
+
+// purgeTimedRotatingFiles removes the outdated backups of pathPrefix, keeping at most
+// backupCount of them besides the current file. currentName is only called when
+// something needs to be removed, and the file it names is never removed.
+func purgeTimedRotatingFiles(pathPrefix string, rotateDuration RotateDuration, backupCount uint8, currentName func() string) {
+	pathes, err := filepath.Glob(pathPrefix + "*")
+	if err != nil {
+		logError(err)
+		return
+	}
+
+	pathes = filterTimedRotatingFiles(pathes, pathPrefix, rotateDuration)
+	count := len(pathes) - int(backupCount) - 1
+	if count > 0 {
+		name := currentName()
 		sort.Strings(pathes)
 		for i := 0; i < count; i++ {
 			path := pathes[i]
@@ -573,12 +642,27 @@ var nextRotateDuration = func(rotateDuration RotateDuration) time.Duration {
 	return nextTime.Sub(now)
 }
 
+// A ConcurrentFileWriter is a buffered file writer designed for high concurrency.
+// Each P writes into its own shard buffer, and the shards are drained into the file
+// every 0.1 second.
 type ConcurrentFileWriter struct {
 	bufferedFileWriter
 	cpuCount        int
 	shardBufferSize uint32
 	locks           []sync.Mutex
 	buffers         []*bytes.Buffer
+	seq             atomic.Uint64   // the last sequence number, only used in ordered mode
+	marks           [][]recordMark  // the records in each shard buffer, only used in ordered mode
+	spares          []*bytes.Buffer // swapped with buffers when draining in ordered mode
+	spareMarks      [][]recordMark  // swapped with marks when draining in ordered mode
+	spareCapacity   atomic.Int64    // the capacity of the spare buffers, only updated by drainOrdered()
+	peaks           []int           // the largest length of each shard buffer
+	drainChan       chan struct{}   // requests schedule() to drain early
+	flushChan       chan chan error // requests schedule() to drain and flush, then reply the error
+	drainedLock     sync.Mutex
+	drainedChan     chan struct{} // closed and replaced after each drain
+	earlyDrains     atomic.Uint64
+	blockedWrites   atomic.Uint64
 	stopChan        chan struct{}
 	stoppedChan     chan struct{}
 	closeOnce       sync.Once
@@ -586,13 +670,22 @@ type ConcurrentFileWriter struct {
 	closed          atomic.Bool
 }
 
-// NewConcurrentFileWriter creates a new ConcurrentFileWriter.
-func NewConcurrentFileWriter(path string, options ...BufferedFileWriterOption) (*ConcurrentFileWriter, error) {
-	f, err := os.OpenFile(path, fileFlag, fileMode)
-	if err != nil {
-		return nil, err
-	}
+// ConcurrentFileWriterStats contains the memory statistics of a ConcurrentFileWriter.
+type ConcurrentFileWriterStats struct {
+	Shards        int    // the number of shards
+	HighWaterMark int    // the shard size which triggers an early drain
+	BufferedBytes int    // the bytes pending in the shard buffers
+	Capacity      int    // the capacity allocated by the shard buffers
+	PeakShardSize int    // the largest length reached by a shard buffer
+	EarlyDrains   uint64 // the number of drains triggered by the high-water mark
+	BlockedWrites uint64 // the number of writes blocked until their shard was drained
+}
 
+// initConcurrentFileWriter populates the embedded ConcurrentFileWriter inside any of
+// the concurrent writer variants (ConcurrentFileWriter, ConcurrentRotatingFileWriter,
+// ConcurrentTimedRotatingFileWriter), like initBufferedFileWriter does for the
+// buffered ones.
+func initConcurrentFileWriter(w *ConcurrentFileWriter, f *os.File, options []BufferedFileWriterOption) {
 	// One shard per P. The slices are sized to the GOMAXPROCS value seen here;
 	// runtime_procPin may later return an id outside this range if GOMAXPROCS
 	// grows, so Write maps the P id back in with a modulo. Shard buffers are
@@ -600,17 +693,17 @@ func NewConcurrentFileWriter(path string, options ...BufferedFileWriterOption) (
 	// nothing.
 	cpuCount := runtime.GOMAXPROCS(0)
 
-	w := &ConcurrentFileWriter{
-		bufferedFileWriter: bufferedFileWriter{
-			file:       f,
-			bufferSize: defaultBufferSize,
-		},
-		cpuCount:    cpuCount,
-		locks:       make([]sync.Mutex, cpuCount),
-		buffers:     make([]*bytes.Buffer, cpuCount),
-		stopChan:    make(chan struct{}),
-		stoppedChan: make(chan struct{}),
-	}
+	w.file = f
+	w.bufferSize = defaultBufferSize
+	w.cpuCount = cpuCount
+	w.locks = make([]sync.Mutex, cpuCount)
+	w.buffers = make([]*bytes.Buffer, cpuCount)
+	w.peaks = make([]int, cpuCount)
+	w.drainChan = make(chan struct{}, 1)
+	w.flushChan = make(chan chan error)
+	w.drainedChan = make(chan struct{})
+	w.stopChan = make(chan struct{})
+	w.stoppedChan = make(chan struct{})
 
 	for _, option := range options {
 		option(&w.bufferedFileWriter)
@@ -624,9 +717,30 @@ func NewConcurrentFileWriter(path string, options ...BufferedFileWriterOption) (
 	if w.shardBufferSize < minShardBufferSize {
 		w.shardBufferSize = minShardBufferSize
 	}
+	if w.highWaterMark == 0 {
+		w.highWaterMark = w.shardBufferSize
+	} else if w.shardBufferSize/2 > w.highWaterMark {
+		w.shardBufferSize = w.highWaterMark * 2 // a shard never needs more
+	}
+
+	if w.ordered {
+		w.marks = make([][]recordMark, cpuCount)
+		w.spares = make([]*bytes.Buffer, cpuCount)
+		w.spareMarks = make([][]recordMark, cpuCount)
+	}
 
 	w.buffer = bufio.NewWriterSize(f, int(w.bufferSize))
+}
+
+// NewConcurrentFileWriter creates a new ConcurrentFileWriter.
+func NewConcurrentFileWriter(path string, options ...BufferedFileWriterOption) (*ConcurrentFileWriter, error) {
+	f, err := os.OpenFile(path, fileFlag, fileMode)
+	if err != nil {
+		return nil, err
+	}
 
+	w := &ConcurrentFileWriter{}
+	initConcurrentFileWriter(w, f, options)
 	go w.schedule()
 	return w, nil
 }
@@ -636,24 +750,15 @@ func (w *ConcurrentFileWriter) schedule() {
 	for {
 		select {
 		case <-timer.C:
-			for shard := 0; shard < w.cpuCount; shard++ {
-				w.locks[shard].Lock()
-				buffer := w.buffers[shard]
-				if buffer != nil && buffer.Len() > 0 {
-					w.buffer.Write(buffer.Bytes())
-					buffer.Reset()
-				}
-				w.locks[shard].Unlock()
-			}
-
-			if w.buffer.Buffered() > 0 {
-				err := w.buffer.Flush()
-				if err != nil {
-					logError(err)
-				}
-			}
-
+			w.drain()
+			w.flush()
 			timer.Reset(flushDuration)
+		case <-w.drainChan:
+			w.earlyDrains.Add(1)
+			w.drain()
+		case ch := <-w.flushChan:
+			w.drain()
+			ch <- w.flushBuffer()
 		case <-w.stopChan:
 			stopTimer(timer)
 			close(w.stoppedChan)
@@ -662,6 +767,145 @@ func (w *ConcurrentFileWriter) schedule() {
 	}
 }
 
+// requestDrain asks schedule() to drain the shards without waiting for its timer.
+func (w *ConcurrentFileWriter) requestDrain() {
+	select { // ignores if a drain has already been requested
+	case w.drainChan <- struct{}{}:
+	default:
+	}
+}
+
+// drained returns a channel which will be closed after the next drain.
+func (w *ConcurrentFileWriter) drained() chan struct{} {
+	w.drainedLock.Lock()
+	ch := w.drainedChan
+	w.drainedLock.Unlock()
+	return ch
+}
+
+// notifyDrained wakes up the writers blocked until a drain.
+func (w *ConcurrentFileWriter) notifyDrained() {
+	w.drainedLock.Lock()
+	close(w.drainedChan)
+	w.drainedChan = make(chan struct{})
+	w.drainedLock.Unlock()
+}
+
+// drain moves the pending bytes of all the shards into the aggregate buffer and
+// returns how many bytes were moved. It should only be called by the schedule
+// goroutine, or after it has stopped.
+func (w *ConcurrentFileWriter) drain() (n int) {
+	defer w.notifyDrained()
+	if w.ordered {
+		return w.drainOrdered()
+	}
+	for shard := 0; shard < w.cpuCount; shard++ {
+		w.locks[shard].Lock()
+		buffer := w.buffers[shard]
+		if buffer != nil && buffer.Len() > 0 {
+			if w.buffer != nil { // nil after a failed rotation
+				w.buffer.Write(buffer.Bytes())
+			}
+			n += buffer.Len()
+			buffer.Reset()
+		}
+		w.locks[shard].Unlock()
+	}
+	return
+}
+
+// A recordMark locates a record written in ordered mode inside its shard buffer.
+type recordMark struct {
+	seq uint64
+	end int // the end offset of the record in the shard buffer
+}
+
+// drainOrdered is the drain of ordered mode.
+//
+// It holds all the shard locks at once while swapping the shard buffers with the
+// spare ones. Because sequence numbers are taken under the shard locks, the swapped
+// buffers then contain every record numbered so far and none numbered later, so
+// merging them by sequence number keeps the order across flush cycles too.
+func (w *ConcurrentFileWriter) drainOrdered() (n int) {
+	for shard := 0; shard < w.cpuCount; shard++ {
+		w.locks[shard].Lock()
+	}
+	for shard := 0; shard < w.cpuCount; shard++ {
+		if len(w.marks[shard]) > 0 {
+			w.buffers[shard], w.spares[shard] = w.spares[shard], w.buffers[shard]
+			w.marks[shard], w.spareMarks[shard] = w.spareMarks[shard], w.marks[shard]
+		}
+	}
+	for shard := 0; shard < w.cpuCount; shard++ {
+		w.locks[shard].Unlock()
+	}
+
+	starts := make([]int, w.cpuCount)
+	indexes := make([]int, w.cpuCount)
+	for {
+		next := -1
+		var minSeq uint64
+		for shard, marks := range w.spareMarks {
+			if i := indexes[shard]; i < len(marks) && (next < 0 || marks[i].seq < minSeq) {
+				next = shard
+				minSeq = marks[i].seq
+			}
+		}
+		if next < 0 {
+			break
+		}
+
+		end := w.spareMarks[next][indexes[next]].end
+		if w.buffer != nil { // nil after a failed rotation
+			w.buffer.Write(w.spares[next].Bytes()[starts[next]:end])
+		}
+		n += end - starts[next]
+		starts[next] = end
+		indexes[next]++
+	}
+
+	var capacity int
+	for shard, marks := range w.spareMarks {
+		if len(marks) > 0 {
+			w.spares[shard].Reset()
+			w.spareMarks[shard] = marks[:0]
+		}
+		if spare := w.spares[shard]; spare != nil {
+			capacity += spare.Cap()
+		}
+	}
+	w.spareCapacity.Store(int64(capacity)) // Stats() can't read the spare buffers while they are merged
+	return
+}
+
+// flush flushes the aggregate buffer to the file, logging any error.
+func (w *ConcurrentFileWriter) flush() {
+	if err := w.flushBuffer(); err != nil {
+		logError(err)
+	}
+}
+
+// flushBuffer flushes the aggregate buffer to the file.
+// It should only be called by the schedule goroutine, or after it has stopped.
+func (w *ConcurrentFileWriter) flushBuffer() error {
+	if w.buffer != nil && w.buffer.Buffered() > 0 {
+		return w.buffer.Flush()
+	}
+	return nil
+}
+
+// Flush drains all the shards and flushes them to the file without waiting for the
+// next 0.1 second tick. It's done by the schedule goroutine, and waits for it.
+func (w *ConcurrentFileWriter) Flush() error {
+	ch := make(chan error, 1)
+	select {
+	case w.flushChan <- ch:
+		return <-ch
+	case <-w.stoppedChan:
+		return os.ErrClosed
+	}
+}
+
 // Write writes a byte slice to the buffer.
 func (w *ConcurrentFileWriter) Write(p []byte) (n int, err error) {
 	if w.closed.Load() {
@@ -675,15 +919,71 @@ func (w *ConcurrentFileWriter) Write(p []byte) (n int, err error) {
 	w.locks[shard].Lock()
 	defer w.locks[shard].Unlock()
 
-	if w.closed.Load() {
-		return 0, os.ErrClosed
+	var buffer *bytes.Buffer
+	for {
+		if w.closed.Load() {
+			return 0, os.ErrClosed
+		}
+		buffer = w.buffers[shard]
+		// A record larger than the limit is still accepted by an empty shard.
+		if !w.blocksWrites || buffer == nil || buffer.Len() == 0 || buffer.Len()+len(p) <= int(w.highWaterMark)*2 {
+			break
+		}
+
+		// Applies backpressure: the drained channel is taken while holding the shard
+		// lock, so the drain closing it cannot have passed this shard yet.
+		drained := w.drained()
+		w.locks[shard].Unlock()
+		w.blockedWrites.Add(1)
+		w.requestDrain()
+		<-drained
+		w.locks[shard].Lock()
 	}
-	buffer := w.buffers[shard]
+
 	if buffer == nil {
 		buffer = bytes.NewBuffer(make([]byte, 0, w.shardBufferSize))
 		w.buffers[shard] = buffer
 	}
-	return buffer.Write(p)
+	n, err = buffer.Write(p)
+	if w.ordered {
+		w.marks[shard] = append(w.marks[shard], recordMark{seq: w.seq.Add(1), end: buffer.Len()})
+	}
+
+	length := buffer.Len()
+	if length > w.peaks[shard] {
+		w.peaks[shard] = length
+	}
+	if length >= int(w.highWaterMark) {
+		w.requestDrain()
+	}
+	return
+}
+
+// Stats returns the memory statistics of the writer.
+func (w *ConcurrentFileWriter) Stats() ConcurrentFileWriterStats {
+	stats := ConcurrentFileWriterStats{
+		Shards:        w.cpuCount,
+		HighWaterMark: int(w.highWaterMark),
+		EarlyDrains:   w.earlyDrains.Load(),
+		BlockedWrites: w.blockedWrites.Load(),
+	}
+	for shard := 0; shard < w.cpuCount; shard++ {
+		w.locks[shard].Lock()
+		if !w.closed.Load() {
+			if buffer := w.buffers[shard]; buffer != nil {
+				stats.BufferedBytes += buffer.Len()
+				stats.Capacity += buffer.Cap()
+			}
+		}
+		if w.peaks[shard] > stats.PeakShardSize {
+			stats.PeakShardSize = w.peaks[shard]
+		}
+		w.locks[shard].Unlock()
+	}
+	if w.ordered && !w.closed.Load() {
+		stats.Capacity += int(w.spareCapacity.Load())
+	}
+	return stats
 }
 
 // Close flushes the buffer, then closes the file writer.
@@ -693,17 +993,16 @@ func (w *ConcurrentFileWriter) Close() error {
 		close(w.stopChan) // stops schedule()
 		<-w.stoppedChan   // waits for schedule() to finish, so the rest code can run without its flush loop
 
-		for shard := 0; shard < w.cpuCount; shard++ {
-			w.locks[shard].Lock()
-			buffer := w.buffers[shard]
-			if buffer != nil && buffer.Len() > 0 {
-				w.buffer.Write(buffer.Bytes())
-				buffer.Reset()
-			}
-			w.buffers[shard] = nil
-			w.locks[shard].Unlock()
-		}
+		w.drain()
+		w.buffers = nil
+		w.marks = nil
+		w.spares = nil
+		w.spareMarks = nil
 
+		if w.file == nil { // a failed rotation has already closed it
+			w.buffer = nil
+			return
+		}
 		if w.buffer.Buffered() > 0 {
 			w.closeErr = w.buffer.Flush()
 		}
@@ -717,11 +1016,216 @@ func (w *ConcurrentFileWriter) Close() error {
 		}
 		w.file = nil
 		w.buffer = nil
-		w.buffers = nil
 	})
 	return w.closeErr
 }
 
+// A ConcurrentRotatingFileWriter is a ConcurrentFileWriter which will rotate after reaching its maxSize.
+// The shards are fully drained into the current file before rotating, so a file always
+// contains whole flush cycles, and it may exceed maxSize by the bytes written in the last
+// 0.1 second. It keeps at most backupCount backups, named like RotatingFileWriter's.
+type ConcurrentRotatingFileWriter struct {
+	ConcurrentFileWriter
+	path        string
+	pos         uint64
+	maxSize     uint64
+	backupCount uint8
+}
+
+// NewConcurrentRotatingFileWriter creates a new ConcurrentRotatingFileWriter.
+func NewConcurrentRotatingFileWriter(path string, maxSize uint64, backupCount uint8, options ...BufferedFileWriterOption) (*ConcurrentRotatingFileWriter, error) {
+	if maxSize == 0 {
+		return nil, errors.New("maxSize cannot be 0")
+	}
+
+	if backupCount == 0 {
+		return nil, errors.New("backupCount cannot be 0")
+	}
+
+	f, err := os.OpenFile(path, fileFlag, fileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		if e := f.Close(); e != nil {
+			logError(e)
+		}
+		return nil, err
+	}
+
+	w := &ConcurrentRotatingFileWriter{
+		path:        path,
+		pos:         uint64(stat.Size()),
+		maxSize:     maxSize,
+		backupCount: backupCount,
+	}
+	initConcurrentFileWriter(&w.ConcurrentFileWriter, f, options)
+	go w.schedule()
+	return w, nil
+}
+
+func (w *ConcurrentRotatingFileWriter) schedule() {
+	timer := time.NewTimer(flushDuration)
+	for {
+		select {
+		case <-timer.C:
+			w.drainAndRotate()
+			w.flush()
+			timer.Reset(flushDuration)
+		case <-w.drainChan:
+			w.earlyDrains.Add(1)
+			w.drainAndRotate()
+		case ch := <-w.flushChan:
+			w.drainAndRotate()
+			ch <- w.flushBuffer()
+		case <-w.stopChan:
+			stopTimer(timer)
+			close(w.stoppedChan)
+			return
+		}
+	}
+}
+
+// drainAndRotate drains the shards, then rotates if reaching maxSize.
+func (w *ConcurrentRotatingFileWriter) drainAndRotate() {
+	if n := w.drain(); n > 0 && w.file != nil {
+		w.pos += uint64(n)
+		if w.pos >= w.maxSize {
+			if err := w.rotate(); err != nil {
+				logError(err)
+			}
+		}
+	}
+}
+
+// rotate rotates the log file. It should only be called by the schedule goroutine.
+// If the new file cannot be opened, the writer is marked as closed.
+func (w *ConcurrentRotatingFileWriter) rotate() error {
+	err := w.buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = w.file.Close()
+	w.pos = 0
+	if err == nil {
+		err = renameBackups(w.path, w.backupCount)
+	}
+	var f *os.File
+	if err == nil {
+		f, err = os.OpenFile(w.path, fileFlag, fileMode)
+	}
+	if err != nil {
+		w.closed.Store(true)
+		w.file = nil
+		w.buffer = nil
+		return err
+	}
+
+	w.file = f
+	w.buffer.Reset(f)
+	return nil
+}
+
+// A ConcurrentTimedRotatingFileWriter is a ConcurrentFileWriter which will rotate by time.
+// The shards are fully drained into the current file at the rotation boundary.
+// Its rotateDuration can be either RotateByDate or RotateByHour.
+// It keeps at most backupCount backups, named like TimedRotatingFileWriter's.
+type ConcurrentTimedRotatingFileWriter struct {
+	ConcurrentFileWriter
+	pathPrefix     string
+	rotateDuration RotateDuration
+	backupCount    uint8
+}
+
+// NewConcurrentTimedRotatingFileWriter creates a new ConcurrentTimedRotatingFileWriter.
+func NewConcurrentTimedRotatingFileWriter(pathPrefix string, rotateDuration RotateDuration, backupCount uint8, options ...BufferedFileWriterOption) (*ConcurrentTimedRotatingFileWriter, error) {
+	if backupCount == 0 {
+		return nil, errors.New("backupCount cannot be 0")
+	}
+
+	f, err := openTimedRotatingFile(pathPrefix, rotateDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &ConcurrentTimedRotatingFileWriter{
+		pathPrefix:     pathPrefix,
+		rotateDuration: rotateDuration,
+		backupCount:    backupCount,
+	}
+	initConcurrentFileWriter(&w.ConcurrentFileWriter, f, options)
+	go w.schedule()
+	return w, nil
+}
+
+func (w *ConcurrentTimedRotatingFileWriter) schedule() {
+	flushTimer := time.NewTimer(flushDuration)
+	rotateTimer := time.NewTimer(nextRotateDuration(w.rotateDuration))
+	for {
+		select {
+		case <-flushTimer.C:
+			w.drain()
+			w.flush()
+			flushTimer.Reset(flushDuration)
+		case <-w.drainChan:
+			w.earlyDrains.Add(1)
+			w.drain()
+		case ch := <-w.flushChan:
+			w.drain()
+			ch <- w.flushBuffer()
+		case <-rotateTimer.C:
+			if err := w.rotate(); err != nil {
+				logError(err)
+			}
+			rotateTimer.Reset(nextRotateDuration(w.rotateDuration))
+		case <-w.stopChan:
+			stopTimer(flushTimer)
+			stopTimer(rotateTimer)
+			close(w.stoppedChan)
+			return
+		}
+	}
+}
+
+// rotate drains all the shards into the current file, then switches to a new one.
+// It should only be called by the schedule goroutine.
+// If the new file cannot be opened, the writer is marked as closed.
+func (w *ConcurrentTimedRotatingFileWriter) rotate() error {
+	w.drain()
+	if w.file == nil { // a previous rotation failed
+		return nil
+	}
+
+	err := w.buffer.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = w.file.Close()
+	var f *os.File
+	if err == nil {
+		f, err = openTimedRotatingFile(w.pathPrefix, w.rotateDuration)
+	}
+	if err != nil {
+		w.closed.Store(true)
+		w.file = nil
+		w.buffer = nil
+		return err
+	}
+
+	w.file = f
+	w.buffer.Reset(f)
+
+	name := f.Name()
+	go purgeTimedRotatingFiles(w.pathPrefix, w.rotateDuration, w.backupCount, func() string {
+		return name
+	})
+	return nil
+}
+
 // runtime_procPin / runtime_procUnpin are used by ConcurrentFileWriter to obtain a
 // stable per-P shard index without atomic contention. The public runtime API does not
 // expose this primitive, and any alternative (atomic round-robin, hash of goroutine
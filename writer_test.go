@@ -759,3 +759,437 @@ func TestConcurrentFileWriterShardBufferScaling(t *testing.T) {
 		t.Fatalf("shard buffer size is %d, expected %d", w.shardBufferSize, want)
 	}
 }
+
+func TestConcurrentRotatingFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.log")
+
+	_, err := NewConcurrentRotatingFileWriter(path, 0, 2)
+	if err == nil {
+		t.Errorf("NewConcurrentRotatingFileWriter with maxSize 0 is invalid")
+	}
+
+	_, err = NewConcurrentRotatingFileWriter(path, 128, 0)
+	if err == nil {
+		t.Errorf("NewConcurrentRotatingFileWriter with backupCount 0 is invalid")
+	}
+
+	w, err := NewConcurrentRotatingFileWriter(path, 128, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, path, 0)
+
+	bs := []byte("0123456789")
+	for i := 0; i < 20; i++ {
+		w.Write(bs)
+	}
+	// all the shards are drained into the current file before rotating
+	checkFileSizeN(t, path+".1", 200)
+	checkFileSize(t, path, 0)
+
+	w.Write(bs)
+	checkFileSizeN(t, path, 10)
+	_, err = os.Stat(path + ".2")
+	if !os.IsNotExist(err) {
+		t.Error(err)
+	}
+
+	for i := 0; i < 12; i++ {
+		w.Write(bs)
+	}
+	checkFileSizeN(t, path+".2", 200)
+	checkFileSize(t, path+".1", 130)
+	checkFileSize(t, path, 0)
+
+	w.Write(bs)
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	checkFileSize(t, path, 10)
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+}
+
+func TestConcurrentTimedRotatingFileWriter(t *testing.T) {
+	dir := t.TempDir()
+	pathPrefix := filepath.Join(dir, "test")
+
+	tm := time.Date(2018, 11, 19, 16, 12, 34, 56, time.Local)
+	var lock sync.RWMutex
+	setNowFunc(func() time.Time {
+		lock.RLock()
+		now := tm
+		lock.RUnlock()
+		return now
+	})
+	var setNow = func(now time.Time) {
+		lock.Lock()
+		tm = now
+		lock.Unlock()
+	}
+
+	// Each rotation is triggered by the test: nextRotateDuration blocks the schedule
+	// goroutine until rotateChan is fed, then lets the rotate timer fire at once.
+	rotateChan := make(chan struct{})
+	oldNextRotateDuration := nextRotateDuration
+	nextRotateDuration = func(rotateDuration RotateDuration) time.Duration {
+		<-rotateChan
+		return 0
+	}
+	defer func() {
+		setNowFunc(time.Now)
+		nextRotateDuration = oldNextRotateDuration
+	}()
+	rotate := func() {
+		rotateChan <- struct{}{}
+		rotateChan <- struct{}{} // returns after the previous rotation has finished
+	}
+
+	_, err := NewConcurrentTimedRotatingFileWriter(pathPrefix, RotateByDate, 0)
+	if err == nil {
+		t.Errorf("NewConcurrentTimedRotatingFileWriter with backupCount 0 is invalid")
+	}
+
+	go func() { rotateChan <- struct{}{} }()
+	w, err := NewConcurrentTimedRotatingFileWriter(pathPrefix, RotateByDate, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := pathPrefix + "-20181119.log"
+	checkFileSize(t, path, 0)
+
+	w.Write([]byte("123"))
+	setNow(time.Date(2018, 11, 20, 16, 12, 34, 56, time.Local))
+	rotate()
+	checkFileSize(t, path, 3)
+	path = pathPrefix + "-20181120.log"
+	checkFileSize(t, path, 0)
+
+	w.Write([]byte("4567"))
+	setNow(time.Date(2018, 11, 21, 16, 12, 34, 56, time.Local))
+	rotate()
+	checkFileSize(t, path, 4)
+	checkFileSize(t, pathPrefix+"-20181121.log", 0)
+
+	for i := 0; i < maxRetryCount; i++ {
+		_, err = os.Stat(pathPrefix + "-20181119.log")
+		if os.IsNotExist(err) {
+			break
+		}
+		time.Sleep(flushDuration)
+	}
+	if !os.IsNotExist(err) {
+		t.Errorf("outdated backup was not purged: %v", err)
+	}
+
+	w.Write([]byte("89"))
+	go func() { // unblocks schedule() so it can stop
+		for {
+			select {
+			case rotateChan <- struct{}{}:
+			case <-time.After(time.Second):
+				return
+			}
+		}
+	}()
+	if err := w.Close(); err != nil {
+		t.Error(err)
+	}
+	checkFileSize(t, pathPrefix+"-20181121.log", 2)
+	if _, err := w.Write([]byte("closed")); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Write() after Close() error is %v, expected %v", err, os.ErrClosed)
+	}
+}
+
+func TestConcurrentFileWriterOrdered(t *testing.T) {
+	old := runtime.GOMAXPROCS(4)
+	defer runtime.GOMAXPROCS(old)
+
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path, BufferSize(1024), Ordered())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 8
+	const writes = 2000
+	var lock sync.Mutex
+	var count int
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				// the lock orders the Write() calls, which may land on different shards
+				lock.Lock()
+				count++
+				w.Write([]byte(strconv.Itoa(count) + "\n"))
+				lock.Unlock()
+				if j%100 == 0 {
+					runtime.Gosched()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != goroutines*writes {
+		t.Fatalf("read %d lines, expected %d lines", len(lines), goroutines*writes)
+	}
+	for i, line := range lines {
+		if line != strconv.Itoa(i+1) {
+			t.Fatalf("line %d is %s", i+1, line)
+		}
+	}
+	if w.marks != nil || w.spares != nil {
+		t.Fatal("Close should release the ordered mode buffers")
+	}
+}
+
+func TestConcurrentFileWriterHighWaterMark(t *testing.T) {
+	old := runtime.GOMAXPROCS(2)
+	defer runtime.GOMAXPROCS(old)
+
+	const highWaterMark = 1024
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path, BufferSize(1024*1024), ShardHighWaterMark(highWaterMark))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats := w.Stats()
+	if stats.HighWaterMark != highWaterMark {
+		t.Errorf("high-water mark is %d, expected %d", stats.HighWaterMark, highWaterMark)
+	}
+	if stats.Capacity != 0 || stats.BufferedBytes != 0 {
+		t.Errorf("unused writer has stats %+v", stats)
+	}
+
+	const goroutines = 4
+	const writes = 5000
+	data := []byte(strings.Repeat("x", 99) + "\n")
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if _, err := w.Write(data); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	stats = w.Stats()
+	if stats.PeakShardSize > highWaterMark*2 {
+		t.Errorf("peak shard size is %d, expected at most %d", stats.PeakShardSize, highWaterMark*2)
+	}
+	if stats.EarlyDrains == 0 {
+		t.Error("reaching the high-water mark did not drain early")
+	}
+	if stats.Capacity > stats.Shards*highWaterMark*2 {
+		t.Errorf("shard capacity is %d, expected at most %d", stats.Capacity, stats.Shards*highWaterMark*2)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, path, int64(goroutines*writes*len(data)))
+	if stats = w.Stats(); stats.BufferedBytes != 0 || stats.Capacity != 0 {
+		t.Errorf("closed writer has stats %+v", stats)
+	}
+}
+
+func TestConcurrentFileWriterOrderedStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path, BufferSize(1024), Ordered())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 4
+	const writes = 2000
+	data := []byte(strings.Repeat("x", 99) + "\n")
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if _, err := w.Write(data); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	// reads the stats while the spare buffers are merged by the early drains
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for stop := false; !stop; {
+		select {
+		case <-done:
+			stop = true
+		default:
+		}
+		if stats := w.Stats(); stats.Capacity < stats.BufferedBytes {
+			t.Errorf("capacity %d is less than the buffered %d bytes", stats.Capacity, stats.BufferedBytes)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, path, int64(goroutines*writes*len(data)))
+	if stats := w.Stats(); stats.Capacity != 0 {
+		t.Errorf("closed writer has stats %+v", stats)
+	}
+}
+
+func TestConcurrentFileWriterNonBlocking(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path, BufferSize(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const goroutines = 4
+	const writes = 5000
+	data := []byte(strings.Repeat("x", 99) + "\n")
+	var wg sync.WaitGroup
+	wg.Add(goroutines)
+	for i := 0; i < goroutines; i++ {
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				if _, err := w.Write(data); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	// without ShardHighWaterMark, the shards are still drained early, but never block
+	stats := w.Stats()
+	if stats.HighWaterMark != minShardBufferSize {
+		t.Errorf("high-water mark is %d, expected %d", stats.HighWaterMark, minShardBufferSize)
+	}
+	if stats.EarlyDrains == 0 {
+		t.Error("reaching the high-water mark did not drain early")
+	}
+	if stats.BlockedWrites != 0 {
+		t.Errorf("%d writes are blocked", stats.BlockedWrites)
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, path, int64(goroutines*writes*len(data)))
+}
+
+func TestConcurrentFileWriterLargeRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path, BufferSize(1024), ShardHighWaterMark(1024))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a record larger than twice the high-water mark is accepted without blocking forever
+	data := make([]byte, 4096)
+	for i := 0; i < 3; i++ {
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkFileSize(t, path, int64(len(data)*3))
+}
+
+func TestWriterFlush(t *testing.T) {
+	newWriters := map[string]func(path string) (io.WriteCloser, error){
+		"buffered": func(path string) (io.WriteCloser, error) { return NewBufferedFileWriter(path) },
+		"rotating": func(path string) (io.WriteCloser, error) { return NewRotatingFileWriter(path, 1024*1024, 1) },
+		"timed": func(path string) (io.WriteCloser, error) {
+			return NewTimedRotatingFileWriter(path, RotateByDate, 1)
+		},
+		"concurrent": func(path string) (io.WriteCloser, error) { return NewConcurrentFileWriter(path) },
+		"concurrent_rotating": func(path string) (io.WriteCloser, error) {
+			return NewConcurrentRotatingFileWriter(path, 1024*1024, 1)
+		},
+		"concurrent_timed": func(path string) (io.WriteCloser, error) {
+			return NewConcurrentTimedRotatingFileWriter(path, RotateByDate, 1)
+		},
+	}
+	for name, newWriter := range newWriters {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.log")
+			w, err := newWriter(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := w.(interface{ Flush() error })
+
+			w.Write([]byte("test"))
+			if err = f.Flush(); err != nil {
+				t.Fatal(err)
+			}
+			paths, err := filepath.Glob(path + "*")
+			if err != nil || len(paths) != 1 {
+				t.Fatalf("files are %v, %v", paths, err)
+			}
+			content, err := os.ReadFile(paths[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "test" {
+				t.Errorf("content is %q before the flush interval", content)
+			}
+
+			w.Close()
+			if err = f.Flush(); err != os.ErrClosed {
+				t.Errorf("flushing a closed writer returned %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkConcurrentFileWriterParallel(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "test.log")
+	w, err := NewConcurrentFileWriter(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := []byte(strings.Repeat("x", 99) + "\n")
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			w.Write(data)
+		}
+	})
+	w.Close()
+}